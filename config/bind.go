@@ -0,0 +1,195 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Bind fills the struct v points to. Fields are read from the key in their
+// config tag, or their lowercased name, and nested structs from keys below
+// theirs:
+//
+//	type Settings struct {
+//		Port     int           `config:"port,required"`
+//		Timeout  time.Duration `config:"timeout"`
+//		Database struct {
+//			Host string `config:"host"`
+//		} `config:"db"`
+//	}
+//
+// Fields without a value keep what they had, so v can carry defaults.
+func (c *Config) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: bind requires a pointer to a struct, got %T", v)
+	}
+
+	var missing []string
+	if err := c.bind(rv.Elem(), "", &missing); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("config: missing required keys: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func (c *Config) bind(v reflect.Value, prefix string, missing *[]string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, required := strings.ToLower(field.Name), false
+		if tag, ok := field.Tag.Lookup("config"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+
+			if parts[0] != "" {
+				name = parts[0]
+			}
+
+			for _, opt := range parts[1:] {
+				required = required || opt == "required"
+			}
+		}
+
+		key := prefix + name
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			if err := c.bind(fv, key+".", missing); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := c.Get(key)
+		if !ok {
+			if required {
+				*missing = append(*missing, key)
+			}
+			continue
+		}
+
+		if err := setValue(fv, value); err != nil {
+			return fmt.Errorf("config: %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// setValue converts a value from any source, a string from the environment
+// or a typed value from yaml or json, into v
+func setValue(v reflect.Value, value interface{}) error {
+	s := formatValue(value)
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := toStrings(value)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// formatValue renders a value as its source wrote it, json numbers would
+// otherwise print as i.e. 1e+06
+func formatValue(v interface{}) string {
+	switch t := v.(type) {
+	case json.Number:
+		return t.String()
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// toStrings returns a yaml or json list as strings, or splits a string on
+// commas
+func toStrings(v interface{}) []string {
+	if list, ok := v.([]interface{}); ok {
+		s := make([]string, 0, len(list))
+		for _, item := range list {
+			s = append(s, formatValue(item))
+		}
+
+		return s
+	}
+
+	var s []string
+	for _, item := range strings.Split(fmt.Sprint(v), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			s = append(s, item)
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,349 @@
+// Package config loads layered service configuration from YAML, JSON and
+// .env files and the environment, and binds it into structs.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rinkbase/grinder"
+	"gopkg.in/yaml.v2"
+)
+
+// Options configuration for Load
+type Options struct {
+	// Files are read in order, later files overriding earlier ones. The format
+	// comes from the extension: .yml, .yaml, .json or .env.
+	Files []string
+
+	// Optional files are skipped when they do not exist, i.e. a local override
+	Optional []string
+
+	// Env lets environment variables override every file. The key db.host is
+	// read from <EnvPrefix>DB_HOST.
+	Env       bool
+	EnvPrefix string
+
+	Defaults map[string]interface{} // Used when no file or variable sets a key
+	Required []string               // Keys Load fails without
+}
+
+// Config is a loaded configuration, safe for concurrent use
+type Config struct {
+	options  Options
+	snapshot atomic.Pointer[snapshot]
+	reload   sync.Mutex // one Reload at a time, so snapshots are swapped in order
+	watchers watchers
+}
+
+// snapshot is one parse of every source, replaced as a whole
+type snapshot struct {
+	layers []map[string]interface{} // flattened keys, lowest precedence first
+}
+
+// Service describes the running service, from the name, description,
+// version and port keys
+type Service struct {
+	Name        string
+	Description string
+	Version     string
+	Port        string
+}
+
+// Load reads every source in options and checks the required keys
+func Load(options Options) (*Config, error) {
+	c := &Config{options: options}
+
+	s, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.snapshot.Store(s)
+	return c, nil
+}
+
+// load parses every source into a new snapshot
+func (c *Config) load() (*snapshot, error) {
+	s := &snapshot{}
+
+	if len(c.options.Defaults) > 0 {
+		s.layers = append(s.layers, flatten(c.options.Defaults))
+	}
+
+	optional := make(map[string]bool)
+	for _, f := range c.options.Optional {
+		optional[f] = true
+	}
+
+	for _, f := range c.files() {
+		layer, err := readFile(f)
+		if os.IsNotExist(err) && optional[f] {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		s.layers = append(s.layers, layer)
+	}
+
+	var missing []string
+	for _, key := range c.options.Required {
+		if _, ok := c.lookup(s, key); !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("config: missing required keys: %s", strings.Join(missing, ", "))
+	}
+
+	return s, nil
+}
+
+// files returns Files followed by any Optional files not already listed
+func (c *Config) files() []string {
+	files := append([]string{}, c.options.Files...)
+
+	for _, o := range c.options.Optional {
+		listed := false
+		for _, f := range c.options.Files {
+			listed = listed || f == o
+		}
+
+		if !listed {
+			files = append(files, o)
+		}
+	}
+
+	return files
+}
+
+// Get returns the value of a key, i.e. db.host, with environment variables
+// taking precedence over files and files over defaults
+func (c *Config) Get(key string) (interface{}, bool) {
+	return c.lookup(c.snapshot.Load(), key)
+}
+
+func (c *Config) lookup(s *snapshot, key string) (interface{}, bool) {
+	key = strings.ToLower(key)
+
+	if c.options.Env {
+		name := c.options.EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+		if v, ok := os.LookupEnv(name); ok {
+			return v, true
+		}
+	}
+
+	// .env files name db.host as db_host
+	alt := strings.Replace(key, ".", "_", -1)
+
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		if v, ok := s.layers[i][key]; ok {
+			return v, true
+		}
+
+		if v, ok := s.layers[i][alt]; ok {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// Has reports whether any source sets key
+func (c *Config) Has(key string) bool {
+	_, ok := c.Get(key)
+	return ok
+}
+
+// String returns key as a string, or "" when unset
+func (c *Config) String(key string) string {
+	var s string
+	c.get(key, &s)
+	return s
+}
+
+// Int returns key as an int, or 0 when unset or not a number
+func (c *Config) Int(key string) int {
+	var n int
+	c.get(key, &n)
+	return n
+}
+
+// Bool returns key as a bool, or false when unset
+func (c *Config) Bool(key string) bool {
+	var b bool
+	c.get(key, &b)
+	return b
+}
+
+// Duration returns key as a time.Duration, i.e. 1m30s, or 0 when unset
+func (c *Config) Duration(key string) time.Duration {
+	var d time.Duration
+	c.get(key, &d)
+	return d
+}
+
+// Strings returns key as a list, a string value is split on commas
+func (c *Config) Strings(key string) []string {
+	var s []string
+	c.get(key, &s)
+	return s
+}
+
+func (c *Config) get(key string, out interface{}) {
+	if v, ok := c.Get(key); ok {
+		setValue(reflect.ValueOf(out).Elem(), v)
+	}
+}
+
+// Keys returns every key set by a file or default, sorted
+func (c *Config) Keys() []string {
+	seen := make(map[string]bool)
+	for _, layer := range c.snapshot.Load().layers {
+		for k := range layer {
+			seen[k] = true
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Service returns the name, description, version and port of the service
+func (c *Config) Service() Service {
+	return Service{
+		Name:        c.String("name"),
+		Description: c.String("description"),
+		Version:     c.String("version"),
+		Port:        c.String("port"),
+	}
+}
+
+// ServerOptions returns the options to start grinder as the configured
+// service, i.e. svc.StartWith(cfg.ServerOptions()...)
+func (c *Config) ServerOptions() []grinder.ServerOption {
+	s := c.Service()
+
+	var opts []grinder.ServerOption
+	if s.Name != "" {
+		opts = append(opts, grinder.WithName(s.Name))
+	}
+
+	if s.Version != "" {
+		opts = append(opts, grinder.WithVersion(s.Version))
+	}
+
+	if s.Port != "" {
+		opts = append(opts, grinder.WithPort(s.Port))
+	}
+
+	return opts
+}
+
+// readFile parses a file into flattened keys
+func readFile(path string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		var v map[interface{}]interface{}
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return nil, fmt.Errorf("config: %s: %v", path, err)
+		}
+
+		return flatten(normalize(v).(map[string]interface{})), nil
+	case ".json":
+		// numbers are kept as written, large integers don't fit a float64
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+
+		var v map[string]interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil, fmt.Errorf("config: %s: %v", path, err)
+		}
+
+		return flatten(v), nil
+	case ".env":
+		env, err := godotenv.Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: %s: %v", path, err)
+		}
+
+		layer := make(map[string]interface{}, len(env))
+		for k, v := range env {
+			layer[strings.ToLower(k)] = v
+		}
+
+		return layer, nil
+	}
+
+	return nil, fmt.Errorf("config: %s: unsupported format", path)
+}
+
+// normalize turns the map[interface{}]interface{} yaml produces into
+// map[string]interface{}
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprint(k)] = normalize(v)
+		}
+
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = normalize(t[i])
+		}
+
+		return t
+	case nil:
+		return map[string]interface{}{}
+	}
+
+	return v
+}
+
+// flatten turns nested maps into dotted lowercase keys, i.e. db.host
+func flatten(m map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			key := strings.ToLower(prefix + k)
+
+			if nested, ok := v.(map[string]interface{}); ok {
+				walk(key+".", nested)
+				continue
+			}
+
+			flat[key] = v
+		}
+	}
+	walk("", m)
+
+	return flat
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	return path
+}
+
+func TestLoadYAML(t *testing.T) {
+	c, err := Load(Options{Files: []string{"../testdata/config.yml"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, Service{
+		Name:        "Grinder",
+		Description: "Tests for Grinder Framework",
+		Version:     "0.1.0",
+		Port:        "9999",
+	}, c.Service())
+
+	assert.Equal(t, 9999, c.Int("port"))
+	assert.Equal(t, []string{"description", "name", "port", "version"}, c.Keys())
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.yml", `
+name: orders
+db:
+  host: localhost
+  port: 5432
+timeout: 5s
+origins:
+  - https://a.example
+  - https://b.example
+`)
+	override := writeFile(t, dir, "production.json", `{"db": {"host": "db.internal"}, "debug": false}`)
+	env := writeFile(t, dir, "local.env", "DB_PORT=6543\nDEBUG=true\n")
+
+	t.Setenv("ORDERS_DB_HOST", "db.override")
+
+	c, err := Load(Options{
+		Files:     []string{base, override, env},
+		Optional:  []string{filepath.Join(dir, "missing.yml")},
+		Env:       true,
+		EnvPrefix: "ORDERS_",
+		Defaults:  map[string]interface{}{"workers": 4, "name": "default"},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "orders", c.String("name"))
+	assert.Equal(t, "db.override", c.String("db.host"))
+	assert.Equal(t, 6543, c.Int("db.port"))
+	assert.True(t, c.Bool("debug"))
+	assert.Equal(t, 5*time.Second, c.Duration("timeout"))
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, c.Strings("origins"))
+	assert.Equal(t, 4, c.Int("workers"))
+	assert.False(t, c.Has("missing"))
+
+	t.Setenv("ORDERS_ORIGINS", "https://c.example, https://d.example")
+	assert.Equal(t, []string{"https://c.example", "https://d.example"}, c.Strings("origins"))
+}
+
+func TestLoadRequired(t *testing.T) {
+	_, err := Load(Options{
+		Files:    []string{"../testdata/config.yml"},
+		Required: []string{"name", "db.host", "secret"},
+	})
+	assert.EqualError(t, err, "config: missing required keys: db.host, secret")
+
+	_, err = Load(Options{Files: []string{"missing.yml"}})
+	assert.Error(t, err)
+
+	_, err = Load(Options{Files: []string{writeFile(t, t.TempDir(), "config.toml", "")}})
+	assert.Error(t, err)
+}
+
+func TestBind(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "config.yml", `
+name: orders
+port: 8080
+timeout: 1m
+ratio: 0.5
+origins: [https://a.example]
+db:
+  host: localhost
+`)
+
+	c, err := Load(Options{Files: []string{file}})
+	assert.NoError(t, err)
+
+	var settings struct {
+		Name    string
+		Port    int           `config:"port,required"`
+		Timeout time.Duration `config:"timeout"`
+		Ratio   float64
+		Origins []string
+		Workers int    `config:"workers"`
+		Ignored string `config:"-"`
+		DB      struct {
+			Host string `config:"host,required"`
+			Pool uint   `config:"pool"`
+		} `config:"db"`
+	}
+	settings.Workers = 2
+
+	assert.NoError(t, c.Bind(&settings))
+	assert.Equal(t, "orders", settings.Name)
+	assert.Equal(t, 8080, settings.Port)
+	assert.Equal(t, time.Minute, settings.Timeout)
+	assert.Equal(t, 0.5, settings.Ratio)
+	assert.Equal(t, []string{"https://a.example"}, settings.Origins)
+	assert.Equal(t, 2, settings.Workers)
+	assert.Equal(t, "localhost", settings.DB.Host)
+
+	var required struct {
+		Secret string `config:"secret,required"`
+	}
+	assert.EqualError(t, c.Bind(&required), "config: missing required keys: secret")
+
+	var invalid struct {
+		Name int
+	}
+	assert.Error(t, c.Bind(&invalid))
+	assert.Error(t, c.Bind(settings))
+}
+
+func TestLoadJSONNumbers(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.json", `{"limit": 1000000, "id": 9007199254740993, "ratio": 0.25, "ports": [8080, 1000000]}`)
+
+	c, err := Load(Options{Files: []string{path}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1000000, c.Int("limit"))
+	assert.Equal(t, "1000000", c.String("limit"))
+	assert.Equal(t, []string{"8080", "1000000"}, c.Strings("ports"))
+
+	var v struct {
+		Limit int64   `config:"limit"`
+		ID    uint64  `config:"id"`
+		Ratio float64 `config:"ratio"`
+		Ports []int   `config:"ports"`
+	}
+	assert.NoError(t, c.Bind(&v))
+
+	assert.Equal(t, int64(1000000), v.Limit)
+	assert.Equal(t, uint64(9007199254740993), v.ID)
+	assert.Equal(t, 0.25, v.Ratio)
+	assert.Equal(t, []int{8080, 1000000}, v.Ports)
+}
+
+func TestServerOptions(t *testing.T) {
+	c, err := Load(Options{Files: []string{"../testdata/config.yml"}})
+	assert.NoError(t, err)
+
+	config := grinder.ServerConfig{}
+	for _, opt := range c.ServerOptions() {
+		opt(&config)
+	}
+
+	assert.Equal(t, "Grinder", config.Name)
+	assert.Equal(t, "0.1.0", config.Version)
+	assert.Equal(t, "9999", config.Port)
+}
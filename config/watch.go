@@ -0,0 +1,111 @@
+package config
+
+import (
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rinkbase/grinder/internal/watch"
+)
+
+// watchers holds the subscribers of a Config
+type watchers struct {
+	mu        sync.Mutex
+	callbacks []func(*Config)
+	channels  []chan *Config
+}
+
+// Reload parses every source again and swaps the result in as a whole, so
+// readers see either the old or the new configuration. When a source fails
+// to parse or a required key is missing the old configuration stays. Each
+// subscriber is notified when anything changed.
+func (c *Config) Reload() error {
+	c.reload.Lock()
+	s, err := c.load()
+	if err != nil {
+		c.reload.Unlock()
+		return err
+	}
+
+	old := c.snapshot.Swap(s)
+	c.reload.Unlock()
+
+	// subscribers are told without the lock held, so they may Reload too
+	if reflect.DeepEqual(old.layers, s.layers) {
+		return nil
+	}
+
+	c.watchers.mu.Lock()
+	callbacks := append([]func(*Config){}, c.watchers.callbacks...)
+	channels := append([]chan *Config{}, c.watchers.channels...)
+	c.watchers.mu.Unlock()
+
+	for _, fn := range callbacks {
+		notify(c, fn)
+	}
+
+	for _, ch := range channels {
+		// a subscriber which has not caught up already has a change pending
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// notify calls fn, a callback which panics, i.e. rebuilding middleware from a
+// bad value, is logged rather than taking down the process
+func notify(c *Config, fn func(*Config)) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("config: change callback panicked: %v", p)
+		}
+	}()
+
+	fn(c)
+}
+
+// OnChange calls fn after every reload which changed the configuration. A
+// callback which panics is logged and the callbacks after it still run.
+func (c *Config) OnChange(fn func(*Config)) {
+	c.watchers.mu.Lock()
+	defer c.watchers.mu.Unlock()
+
+	c.watchers.callbacks = append(c.watchers.callbacks, fn)
+}
+
+// Subscribe returns a channel which receives the Config after a reload
+// changed it, changes made while the subscriber is busy are coalesced.
+// Calling cancel stops further notifications.
+func (c *Config) Subscribe() (changes <-chan *Config, cancel func()) {
+	ch := make(chan *Config, 1)
+
+	c.watchers.mu.Lock()
+	c.watchers.channels = append(c.watchers.channels, ch)
+	c.watchers.mu.Unlock()
+
+	return ch, func() {
+		c.watchers.mu.Lock()
+		defer c.watchers.mu.Unlock()
+
+		for i, sub := range c.watchers.channels {
+			if sub == ch {
+				c.watchers.channels = append(c.watchers.channels[:i], c.watchers.channels[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Watch polls the source files every interval and reloads when any has
+// changed, until stop is called. Reload errors are logged.
+func (c *Config) Watch(interval time.Duration) (stop func()) {
+	return watch.New(interval, c.files()...).Watch(func() {
+		if err := c.Reload(); err != nil {
+			log.Println(err)
+		}
+	})
+}
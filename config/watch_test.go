@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// touch rewrites a file with a modification time the poller cannot miss
+func touch(t *testing.T, path string, content string, offset time.Duration) {
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	later := time.Now().Add(offset)
+	assert.NoError(t, os.Chtimes(path, later, later))
+}
+
+func TestReload(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.yml", "rate: 10\n")
+
+	c, err := Load(Options{Files: []string{path}, Required: []string{"rate"}})
+	assert.NoError(t, err)
+
+	var calls atomic.Int32
+	c.OnChange(func(c *Config) {
+		calls.Add(1)
+	})
+
+	changes, cancel := c.Subscribe()
+	defer cancel()
+
+	// nothing changed, nobody is told
+	assert.NoError(t, c.Reload())
+	assert.Equal(t, int32(0), calls.Load())
+
+	touch(t, path, "rate: 20\n", time.Minute)
+	assert.NoError(t, c.Reload())
+	assert.Equal(t, 20, c.Int("rate"))
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, c, <-changes)
+
+	// a broken file or missing required key keeps the old configuration
+	touch(t, path, "rate: [\n", 2*time.Minute)
+	assert.Error(t, c.Reload())
+	touch(t, path, "other: 1\n", 3*time.Minute)
+	assert.Error(t, c.Reload())
+	assert.Equal(t, 20, c.Int("rate"))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestWatch(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.json", `{"origins": ["https://a.example"]}`)
+
+	c, err := Load(Options{Files: []string{path}})
+	assert.NoError(t, err)
+
+	changes, cancel := c.Subscribe()
+	defer cancel()
+
+	stop := c.Watch(5 * time.Millisecond)
+	defer stop()
+
+	touch(t, path, `{"origins": ["https://b.example"]}`, time.Minute)
+
+	select {
+	case c := <-changes:
+		assert.Equal(t, []string{"https://b.example"}, c.Strings("origins"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("change was not noticed")
+	}
+
+	stop()
+	cancel()
+}
+
+func TestReloadFromCallback(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.yml", "rate: 10\n")
+
+	c, err := Load(Options{Files: []string{path}})
+	assert.NoError(t, err)
+
+	// a callback may reload itself, i.e. to pick up a file it just wrote
+	c.OnChange(func(c *Config) {
+		assert.NoError(t, c.Reload())
+	})
+
+	done := make(chan error)
+	go func() {
+		touch(t, path, "rate: 20\n", time.Minute)
+		done <- c.Reload()
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.Equal(t, 20, c.Int("rate"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload deadlocked")
+	}
+}
+
+func TestReloadCallbackPanics(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.yml", "rate: 10\n")
+
+	c, err := Load(Options{Files: []string{path}})
+	assert.NoError(t, err)
+
+	// i.e. a middleware constructor refusing the new values
+	c.OnChange(func(c *Config) {
+		panic("grinder: invalid rate")
+	})
+
+	var calls atomic.Int32
+	c.OnChange(func(c *Config) {
+		calls.Add(1)
+	})
+
+	touch(t, path, "rate: 20\n", time.Minute)
+	assert.NotPanics(t, func() {
+		assert.NoError(t, c.Reload())
+	})
+	assert.Equal(t, int32(1), calls.Load())
+}
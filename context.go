@@ -1,15 +1,20 @@
 package grinder
 
 import (
+	gocontext "context"
+	"crypto/x509"
 	"encoding/json"
 	"net/http"
+	"time"
 )
 
 type (
 	// Context interface
 	Context interface {
 		Request() *http.Request
+		SetRequest(*http.Request)
 		Response() *Response
+		SetResponse(*Response)
 		JSON(int, interface{}) error
 		String(int, string) error
 		Code(int) error
@@ -21,12 +26,34 @@ type (
 		SetHeader(string, string)
 		GetHeader(string) string
 		Redirect(int, string) error
+		Error(error)
+		Path() string
+		SetPath(string)
+		Deadline() (time.Time, bool)
+		Done() <-chan struct{}
+		Err() error
+		Value(interface{}) interface{}
+		WithContext(gocontext.Context) Context
+		RealIP() string
+		Scheme() string
+		Host() string
+		PeerCertificate() *x509.Certificate
 	}
 
 	context struct {
 		request  *http.Request
 		response *Response
 		params   map[string]string
+		form     *formState
+		path     string
+		grinder  *Grinder
+	}
+
+	// formState is shared by the copies WithContext makes, so the form is
+	// parsed once per request
+	formState struct {
+		parsed bool
+		err    error
 	}
 )
 
@@ -34,11 +61,23 @@ func (c *context) Request() *http.Request {
 	return c.request
 }
 
+func (c *context) SetRequest(r *http.Request) {
+	c.request = r
+}
+
 func (c *context) Response() *Response {
 	return c.response
 }
 
+func (c *context) SetResponse(r *Response) {
+	c.response = r
+}
+
 func (c *context) JSON(code int, i interface{}) (err error) {
+	if err = c.Err(); err != nil {
+		return
+	}
+
 	b, err := json.Marshal(i)
 
 	if err != nil {
@@ -53,6 +92,10 @@ func (c *context) JSON(code int, i interface{}) (err error) {
 }
 
 func (c *context) String(code int, s string) (err error) {
+	if err = c.Err(); err != nil {
+		return
+	}
+
 	c.response.Header().Set("Content-Type", "text/html;charset=utf-8")
 	c.response.WriteHeader(code)
 	_, err = c.response.Write([]byte(s))
@@ -60,11 +103,19 @@ func (c *context) String(code int, s string) (err error) {
 }
 
 func (c *context) Code(code int) (err error) {
+	if err = c.Err(); err != nil {
+		return
+	}
+
 	c.response.WriteHeader(code)
 	return nil
 }
 
 func (c *context) HTTPError(code int, message string) (err error) {
+	if err = c.Err(); err != nil {
+		return
+	}
+
 	c.response.Header().Set("Content-Type", "text/html;charset=utf-8")
 	c.response.WriteHeader(code)
 	_, err = c.response.Write([]byte(message))
@@ -72,10 +123,26 @@ func (c *context) HTTPError(code int, message string) (err error) {
 }
 
 func (c *context) Redirect(code int, uri string) (err error) {
-	http.Redirect(c.Response().writer, c.Request(), uri, code)
+	if err = c.Err(); err != nil {
+		return
+	}
+
+	http.Redirect(c.Response(), c.Request(), uri, code)
 	return nil
 }
 
+func (c *context) Error(err error) {
+	HTTPErrorHandler(err, c)
+}
+
+func (c *context) Path() string {
+	return c.path
+}
+
+func (c *context) SetPath(p string) {
+	c.path = p
+}
+
 func (c *context) AddParams(params map[string]string) {
 	if c.params == nil {
 		c.params = make(map[string]string)
@@ -89,19 +156,39 @@ func (c *context) AddParams(params map[string]string) {
 }
 
 func (c *context) GetParam(i string) string {
+	c.parseForm()
 	param := c.params[i]
 	return param
 }
 
 func (c *context) GetParams() map[string]string {
+	c.parseForm()
 	return c.params
 }
 
 func (c *context) HasParam(i string) bool {
+	c.parseForm()
 	_, isset := c.params[i]
 	return isset
 }
 
+// parseForm adds the form params the first time params are read, so they
+// are available to middleware. Body limits set before then apply, the
+// handler parses the form after all route middleware has run.
+func (c *context) parseForm() error {
+	if c.form == nil {
+		c.form = &formState{}
+	}
+
+	if !c.form.parsed {
+		c.form.parsed = true
+		c.form.err = c.request.ParseForm()
+		c.AddParams(parseFormParams(c.request.Form))
+	}
+
+	return c.form.err
+}
+
 func (c *context) SetHeader(k string, v string) {
 	c.response.Header().Set(k, v)
 }
@@ -109,3 +196,37 @@ func (c *context) SetHeader(k string, v string) {
 func (c *context) GetHeader(k string) string {
 	return c.request.Header.Get(k)
 }
+
+func (c *context) Deadline() (time.Time, bool) {
+	return c.request.Context().Deadline()
+}
+
+func (c *context) Done() <-chan struct{} {
+	return c.request.Context().Done()
+}
+
+func (c *context) Err() error {
+	return c.request.Context().Err()
+}
+
+func (c *context) Value(key interface{}) interface{} {
+	return c.request.Context().Value(key)
+}
+
+// WithContext returns a shallow copy of the context with its request bound to
+// ctx, the response and params are shared with the original
+func (c *context) WithContext(ctx gocontext.Context) Context {
+	cc := *c
+	cc.request = c.request.WithContext(ctx)
+	return &cc
+}
+
+// PeerCertificate returns the certificate the client presented over mutual
+// TLS, or nil
+func (c *context) PeerCertificate() *x509.Certificate {
+	if c.request.TLS == nil || len(c.request.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return c.request.TLS.PeerCertificates[0]
+}
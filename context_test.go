@@ -1,11 +1,13 @@
 package grinder
 
 import (
+	gocontext "context"
 	"math"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -155,12 +157,76 @@ func SampleMethod(ctx Context) error {
 func TestHasParam(t *testing.T) {
 	g := New()
 
-	g.GET("/uri", SampleMethod)
+	var c Context
+	g.GET("/uri", func(ctx Context) error {
+		c = ctx
+		return SampleMethod(ctx)
+	})
 
 	r, _ := http.NewRequest("GET", "/uri?query1=1&query2=2", strings.NewReader(JSON))
 	w := httptest.NewRecorder()
 	g.ServeHTTP(w, r)
 
-	assert.True(t, g.GetContext().HasParam("query1"))
-	assert.True(t, g.GetContext().HasParam("query2"))
+	assert.True(t, c.HasParam("query1"))
+	assert.True(t, c.HasParam("query2"))
+}
+
+func TestContextFollowsRequestContext(t *testing.T) {
+	g := New()
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Minute)
+	defer cancel()
+
+	r, _ := http.NewRequest("GET", "/", strings.NewReader(JSON))
+	w := httptest.NewRecorder()
+
+	c := g.NewContext(w, r.WithContext(ctx))
+
+	deadline, ok := c.Deadline()
+	expected, _ := ctx.Deadline()
+
+	assert.True(t, ok)
+	assert.Equal(t, expected, deadline)
+	assert.Nil(t, c.Err())
+
+	cancel()
+
+	<-c.Done()
+	assert.Equal(t, gocontext.Canceled, c.Err())
+}
+
+func TestWithContext(t *testing.T) {
+	g := New()
+
+	r, _ := http.NewRequest("GET", "/", strings.NewReader(JSON))
+	w := httptest.NewRecorder()
+
+	c := g.NewContext(w, r)
+	c.AddParams(map[string]string{"key": "value"})
+
+	type key struct{}
+	cc := c.WithContext(gocontext.WithValue(c.Request().Context(), key{}, "value"))
+
+	assert.Equal(t, "value", cc.Value(key{}))
+	assert.Nil(t, c.Value(key{}))
+	assert.Equal(t, "value", cc.GetParam("key"))
+	assert.Equal(t, c.Response(), cc.Response())
+}
+
+func TestCancelledContextDoesNotWrite(t *testing.T) {
+	g := New()
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+
+	r, _ := http.NewRequest("GET", "/", strings.NewReader(JSON))
+	w := httptest.NewRecorder()
+
+	c := g.NewContext(w, r.WithContext(ctx))
+
+	err := c.JSON(200, user{1, "John Adams"})
+
+	assert.Equal(t, gocontext.Canceled, err)
+	assert.False(t, c.Response().Committed)
+	assert.Empty(t, w.Body.String())
 }
@@ -1,21 +1,39 @@
 package grinder
 
 import (
+	gocontext "context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"strings"
-
-	"github.com/joho/godotenv"
+	"sync"
+	"sync/atomic"
+	"syscall"
 )
 
-// Grinder struct holds router and context for framework
+// Grinder struct holds router and middleware for framework
 type Grinder struct {
-	context Context
 	router  *Router
 	after   []Middleware
+	around  []Middleware
 	before  []Middleware
+	funcs   []MiddlewareFunc
+	proxies []*net.IPNet
+
+	mu           sync.Mutex
+	name         string
+	version      string
+	server       *http.Server
+	hooks        []func(gocontext.Context) error
+	done         chan struct{}
+	shuttingDown bool
+	shutdownErr  error
+	draining     atomic.Bool
+	checks       []*healthCheck
 }
 
 // Handler basic function to router handlers
@@ -24,6 +42,10 @@ type Handler func(Context) error
 // Middleware defines a function to process middleware
 type Middleware func(Context, Handler) Handler
 
+// MiddlewareFunc defines a middleware constructor, it is composed once when a
+// route is registered rather than on every request
+type MiddlewareFunc func(Handler) Handler
+
 // NotFoundHandler default 404 handler for not found routes
 var NotFoundHandler = func(c Context) error {
 	b, _ := json.Marshal("Not Found")
@@ -35,6 +57,48 @@ var NotFoundHandler = func(c Context) error {
 	return NewHTTPError(http.StatusNotFound)
 }
 
+// HTTPErrorHandler default handler for errors returned by the handler chain
+var HTTPErrorHandler = func(err error, c Context) {
+	// the request was cancelled or timed out, there is nobody left to respond to
+	if c.Err() != nil || c.Response().Committed {
+		return
+	}
+
+	he, ok := err.(*HTTPError)
+	if !ok {
+		code := http.StatusInternalServerError
+
+		// bodies over a limit surface as read errors from the handler
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			code = http.StatusRequestEntityTooLarge
+		}
+
+		he = &HTTPError{
+			Code:    code,
+			Message: http.StatusText(code),
+			Inner:   err,
+		}
+	}
+
+	c.JSON(he.Code, he.Message)
+}
+
+// CommitError writes the error response for err straight away, rather than
+// once it reaches ServeHTTP, so middleware observing the request sees the
+// status sent. It returns that status, 200 when nothing was written.
+func CommitError(c Context, err error) int {
+	if err != nil {
+		c.Error(err)
+	}
+
+	if status := c.Response().Status; status != 0 {
+		return status
+	}
+
+	return http.StatusOK
+}
+
 // HTTPError handles structure of new HTTP error
 type HTTPError struct {
 	Code    int
@@ -49,6 +113,10 @@ func NewHTTPError(code int, message ...interface{}) *HTTPError {
 		Message: http.StatusText(code),
 	}
 
+	if len(message) > 0 {
+		err.Message = message[0]
+	}
+
 	return err
 }
 
@@ -61,6 +129,7 @@ func New() *Grinder {
 	// return Grinder struct
 	return &Grinder{
 		router: new(Router),
+		done:   make(chan struct{}),
 	}
 }
 
@@ -71,6 +140,14 @@ func (g *Grinder) Before(m ...Middleware) {
 	}
 }
 
+// Around adds middleware run for every request, including those matching no
+// route, outside Before middleware. It is meant for observing requests, i.e.
+// access logs, metrics and tracing, guards such as authentication belong in
+// Before so unknown paths still get a 404.
+func (g *Grinder) Around(m ...Middleware) {
+	g.around = append(g.around, m...)
+}
+
 // After adds a middleware function to be executed after the route handler
 func (g *Grinder) After(m ...Middleware) {
 	for i := 0; i < len(m); i++ {
@@ -78,9 +155,81 @@ func (g *Grinder) After(m ...Middleware) {
 	}
 }
 
-// GetContext returns current context
-func (g *Grinder) GetContext() Context {
-	return g.context
+// Use adds constructor middleware to every route registered after the call,
+// it runs inside Before middleware and outside route middleware
+func (g *Grinder) Use(m ...MiddlewareFunc) {
+	g.funcs = append(g.funcs, m...)
+}
+
+// Chain composes constructor middleware around h, the first runs outermost
+func Chain(h Handler, m ...MiddlewareFunc) Handler {
+	for i := len(m) - 1; i >= 0; i-- {
+		h = m[i](h)
+	}
+
+	return h
+}
+
+// Adapt turns a Middleware into constructor middleware, the Middleware itself
+// is still called on every request
+func Adapt(m Middleware) MiddlewareFunc {
+	return func(next Handler) Handler {
+		return func(c Context) error {
+			return m(c, next)(c)
+		}
+	}
+}
+
+type httpCallKey struct{}
+
+// httpCall carries the grinder side of a request through net/http middleware
+type httpCall struct {
+	c   Context
+	err error
+}
+
+// AdaptHTTP turns standard func(http.Handler) http.Handler middleware into
+// constructor middleware. Changes it makes to the request or response writer
+// are seen by the rest of the chain, errors from the chain pass back through.
+func AdaptHTTP(m func(http.Handler) http.Handler) MiddlewareFunc {
+	return func(next Handler) Handler {
+		h := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call := r.Context().Value(httpCallKey{}).(*httpCall)
+
+			c := call.c.WithContext(r.Context())
+			c.SetRequest(r)
+			if res, ok := w.(*Response); !ok || res != call.c.Response() {
+				c.SetResponse(NewResponse(w))
+			}
+
+			call.err = next(c)
+		}))
+
+		return func(c Context) error {
+			call := &httpCall{c: c}
+			r := c.Request()
+
+			h.ServeHTTP(c.Response(), r.WithContext(gocontext.WithValue(r.Context(), httpCallKey{}, call)))
+
+			return call.err
+		}
+	}
+}
+
+// WrapHandler turns a http.Handler into a grinder Handler
+func WrapHandler(h http.Handler) Handler {
+	return func(c Context) error {
+		h.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+}
+
+// WrapMiddleware turns standard func(http.Handler) http.Handler middleware
+// into grinder Middleware, see AdaptHTTP to compose it only once
+func WrapMiddleware(m func(http.Handler) http.Handler) Middleware {
+	return func(c Context, handler Handler) Handler {
+		return AdaptHTTP(m)(handler)
+	}
 }
 
 // Group creates a route group with common prefix
@@ -125,63 +274,62 @@ func (g *Grinder) OPTIONS(e string, f Handler, m ...Middleware) {
 	g.add("OPTIONS", e, f, m)
 }
 
-// Start initates the framework to start listening for requests
-func (g *Grinder) Start() {
-	config, err := godotenv.Read()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
+// Mount serves a http.Handler for every method on prefix and the paths
+// beneath it, the prefix is stripped from the request path
+func (g *Grinder) Mount(prefix string, h http.Handler, m ...Middleware) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	handler := WrapHandler(http.StripPrefix(prefix, h))
 
-	name := config["NAME"]
-	port := config["PORT"]
+	for _, method := range []string{"GET", "HEAD", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"} {
+		g.add(method, prefix+wildcard, handler, m)
+	}
+}
 
-	fmt.Println("==> Running " + name + " on port: " + port)
+// startSignals shut down Start and StartTLS gracefully
+var startSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
 
-	err = http.ListenAndServe(":"+port, g)
-	log.Fatal(err)
+// Start initates the framework to start listening for requests, on PORT from
+// the environment or .env, and shuts down gracefully on SIGINT or SIGTERM.
+// See StartWithConfig to handle errors yourself.
+func (g *Grinder) Start() {
+	err := g.StartWithConfig(ServerConfig{Signals: startSignals})
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 func (g *Grinder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	g.context = g.NewContext(w, r)
+	c := g.NewContext(w, r)
 
-	if found, route := g.router.FindRoute(g.context); found != false {
-		// execute middleware chains
-		handler := func(c Context) error {
-			handler := route.Handler()
+	// Route was not found, only around middleware sees it
+	var handler Handler = NotFoundHandler
 
-			// execute middleware chain
-			for i := 0; i < len(route.middleware); i++ {
-				handler = route.middleware[i](c, handler)
-			}
-
-			return handler(g.context)
-		}
+	if found, route := g.router.FindRoute(c); found != false {
+		c.SetPath(route.path)
+		handler = route.chain
 
 		// execute before middleware
-		if len(g.before) > 0 {
-			for i := 0; i < len(g.before); i++ {
-				handler = g.before[i](g.context, handler)
-			}
-		}
-
-		// Execute chain
-		if err := handler(g.context); err != nil {
-			panic(err)
+		for i := 0; i < len(g.before); i++ {
+			handler = g.before[i](c, handler)
 		}
+	}
 
-		// execute after middleware
-		if len(g.after) > 0 {
-			for i := 0; i < len(g.after); i++ {
-				handler = g.after[i](g.context, handler)
-			}
-		}
+	// execute around middleware
+	for i := 0; i < len(g.around); i++ {
+		handler = g.around[i](c, handler)
+	}
 
-		return
+	// Execute chain
+	if err := handler(c); err != nil {
+		c.Error(err)
 	}
 
-	// Route was not found
-	NotFoundHandler(g.context)
-	return
+	// execute after middleware
+	if len(g.after) > 0 {
+		for i := 0; i < len(g.after); i++ {
+			handler = g.after[i](c, handler)
+		}
+	}
 }
 
 // NewContext creates a fresh context for framework
@@ -189,13 +337,20 @@ func (g *Grinder) NewContext(w http.ResponseWriter, r *http.Request) Context {
 	return &context{
 		request:  r,
 		response: NewResponse(w),
+		params:   make(map[string]string),
+		form:     &formState{},
+		grinder:  g,
 	}
 }
 
-func (g *Grinder) add(a string, e string, f Handler, m []Middleware) {
+func (g *Grinder) add(a string, e string, f Handler, m []Middleware, funcs ...MiddlewareFunc) {
+	c := []MiddlewareFunc{}
+	c = append(c, g.funcs...)
+	c = append(c, funcs...)
+
 	p := strings.Split(e, "?")
 	g.router.Add(a, p[0], func(c Context) error {
 		fn := f
 		return fn(c)
-	}, m)
+	}, m, c...)
 }
@@ -1,11 +1,16 @@
 package grinder
 
 import (
+	gocontext "context"
+	"errors"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/joho/godotenv"
@@ -130,8 +135,13 @@ func TestAddPostRoute(t *testing.T) {
 
 func TestConfigLoad(t *testing.T) {
 	// config := config.Load("./testdata")
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("NAME=test\nJWT_SECRET=x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
 	var config map[string]string
-	config, err := godotenv.Read()
+	config, err := godotenv.Read(path)
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
@@ -187,15 +197,13 @@ func TestAddOptionsRoute(t *testing.T) {
 	assert.True(t, reflect.TypeOf(found["OPTIONS/test"]).String() == "grinder.Route")
 }
 
-func TestGetContext(t *testing.T) {
+func TestNewContext(t *testing.T) {
 	g := New()
 
 	r, _ := http.NewRequest("GET", "/", strings.NewReader("JSON"))
 	w := httptest.NewRecorder()
 
-	g.context = g.NewContext(w, r)
-
-	assert.True(t, reflect.TypeOf(g.GetContext()).String() == "*grinder.context")
+	assert.True(t, reflect.TypeOf(g.NewContext(w, r)).String() == "*grinder.context")
 }
 
 func TestNotFoundHandler(t *testing.T) {
@@ -215,6 +223,55 @@ func TestNotFoundHandler(t *testing.T) {
 	}
 }
 
+func TestAroundSeesNotFound(t *testing.T) {
+	g := New()
+
+	g.GET("/", func(c Context) error {
+		return c.Code(200)
+	})
+
+	// guards only run for matched routes, unknown paths still get a 404
+	g.Before(func(c Context, handler Handler) Handler {
+		return func(c Context) error {
+			return NewHTTPError(401)
+		}
+	})
+
+	var seen []string
+	g.Around(func(c Context, handler Handler) Handler {
+		return func(c Context) error {
+			seen = append(seen, c.Request().URL.Path)
+			return handler(c)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/blah", nil))
+	assert.Equal(t, 404, w.Code)
+
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, 401, w.Code)
+
+	assert.Equal(t, []string{"/blah", "/"}, seen)
+}
+
+func TestCommitError(t *testing.T) {
+	g := New()
+
+	w := httptest.NewRecorder()
+	c := g.NewContext(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, 200, CommitError(c, nil))
+	assert.False(t, c.Response().Committed)
+
+	assert.Equal(t, 403, CommitError(c, NewHTTPError(403)))
+	assert.True(t, c.Response().Committed)
+
+	// the response is not written twice
+	assert.Equal(t, 403, CommitError(c, errors.New("later")))
+	assert.Equal(t, 403, w.Code)
+}
+
 func TestErrorHTTP(t *testing.T) {
 	err := &HTTPError{
 		Code:    404,
@@ -223,3 +280,260 @@ func TestErrorHTTP(t *testing.T) {
 
 	assert.Equal(t, "code=404, message=Not Found", err.Error())
 }
+
+func TestHandlerErrorResponse(t *testing.T) {
+	g := New()
+
+	g.GET("/", func(c Context) error {
+		return NewHTTPError(http.StatusForbidden)
+	})
+
+	g.GET("/error", func(c Context) error {
+		return errors.New("something went wrong")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	if assert.Equal(t, 403, w.Code) {
+		assert.Equal(t, "\"Forbidden\"", w.Body.String())
+	}
+
+	r, _ = http.NewRequest("GET", "/error", nil)
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, 500, w.Code)
+}
+
+func TestHandlerErrorAfterCancel(t *testing.T) {
+	g := New()
+
+	g.GET("/", func(c Context) error {
+		return c.JSON(200, "This is a test")
+	})
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+
+	var c Context
+	g.Before(func(ctx Context, handler Handler) Handler {
+		c = ctx
+		return handler
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r.WithContext(ctx))
+
+	assert.False(t, c.Response().Committed)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestUseComposesOnce(t *testing.T) {
+	g := New()
+
+	built := 0
+	order := []string{}
+
+	g.Use(func(next Handler) Handler {
+		built++
+		return func(c Context) error {
+			order = append(order, "use")
+			return next(c)
+		}
+	})
+
+	g.GET("/", func(c Context) error {
+		order = append(order, "handler")
+		return c.Code(200)
+	}, func(c Context, handler Handler) Handler {
+		return func(c Context) error {
+			order = append(order, "route")
+			return handler(c)
+		}
+	})
+
+	for i := 0; i < 3; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		g.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	assert.Equal(t, 1, built)
+	assert.Equal(t, []string{"use", "route", "handler"}, order[:3])
+}
+
+func TestChain(t *testing.T) {
+	order := []string{}
+
+	mw := func(name string) MiddlewareFunc {
+		return func(next Handler) Handler {
+			return func(c Context) error {
+				order = append(order, name)
+				return next(c)
+			}
+		}
+	}
+
+	h := Chain(handler, mw("first"), Adapt(func(c Context, handler Handler) Handler {
+		order = append(order, "adapted")
+		return handler
+	}), mw("last"))
+
+	h(nil)
+
+	assert.Equal(t, []string{"first", "adapted", "last"}, order)
+}
+
+func TestAdaptHTTP(t *testing.T) {
+	g := New()
+
+	g.Use(AdaptHTTP(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Wrapped", "1")
+			r.Header.Set("X-Seen", "1")
+			next.ServeHTTP(w, r)
+		})
+	}))
+
+	g.GET("/", func(c Context) error {
+		return c.String(200, c.GetHeader("X-Seen"))
+	})
+
+	g.GET("/error", func(c Context) error {
+		return NewHTTPError(http.StatusTeapot)
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, "1", w.Header().Get("X-Wrapped"))
+	assert.Equal(t, "1", w.Body.String())
+
+	r, _ = http.NewRequest("GET", "/error", nil)
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
+
+func TestWrapHandler(t *testing.T) {
+	g := New()
+
+	var c Context
+	g.GET("/", WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})), func(ctx Context, handler Handler) Handler {
+		c = ctx
+		return handler
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, http.StatusTeapot, c.Response().Status)
+}
+
+func TestWrapMiddleware(t *testing.T) {
+	g := New()
+
+	g.GET("/", func(c Context) error {
+		return c.Code(200)
+	}, WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Wrapped", "1")
+			next.ServeHTTP(w, r)
+		})
+	}))
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, "1", w.Header().Get("X-Wrapped"))
+}
+
+func TestMount(t *testing.T) {
+	g := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metrics " + r.Method))
+	})
+
+	g.Mount("/legacy/", mux)
+	g.GET("/legacy/override", func(c Context) error {
+		return c.String(200, "grinder")
+	})
+
+	r, _ := http.NewRequest("POST", "/legacy/metrics", nil)
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, "metrics POST", w.Body.String())
+
+	r, _ = http.NewRequest("GET", "/legacy/override", nil)
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, "grinder", w.Body.String())
+
+	r, _ = http.NewRequest("GET", "/legacyx", nil)
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestMountNested(t *testing.T) {
+	g := New()
+
+	g.Mount("/api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("api " + r.URL.Path))
+	}))
+	g.Mount("/api/v2", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v2 " + r.URL.Path))
+	}))
+
+	// map order is random, so route a few times
+	for i := 0; i < 50; i++ {
+		r, _ := http.NewRequest("GET", "/api/v2/users", nil)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+
+		assert.Equal(t, "v2 /users", w.Body.String())
+
+		r, _ = http.NewRequest("GET", "/api/v1/users", nil)
+		w = httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+
+		assert.Equal(t, "api /v1/users", w.Body.String())
+	}
+}
+
+func TestServeHTTPConcurrent(t *testing.T) {
+	g := New()
+
+	g.GET("/users/:id", func(c Context) error {
+		return c.String(200, c.GetParam("id"))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+
+			r, _ := http.NewRequest("GET", "/users/"+id, nil)
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, r)
+
+			assert.Equal(t, id, w.Body.String())
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+}
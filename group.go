@@ -4,9 +4,16 @@ package grinder
 type Group struct {
 	prefix     string
 	middleware []Middleware
+	funcs      []MiddlewareFunc
 	grinder    *Grinder
 }
 
+// Use adds constructor middleware to every group route registered after the
+// call, it runs inside the Grinder's constructor middleware
+func (g *Group) Use(m ...MiddlewareFunc) {
+	g.funcs = append(g.funcs, m...)
+}
+
 // GET adds a HTTP GET method to the group
 func (g *Group) GET(e string, f Handler, m ...Middleware) {
 	g.add("GET", e, f, m...)
@@ -36,5 +43,5 @@ func (g *Group) add(method string, e string, h Handler, middleware ...Middleware
 	m := []Middleware{}
 	m = append(m, g.middleware...)
 	m = append(m, middleware...)
-	g.grinder.add(method, g.prefix+e, h, m)
+	g.grinder.add(method, g.prefix+e, h, m, g.funcs...)
 }
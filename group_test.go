@@ -1,6 +1,8 @@
 package grinder
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
@@ -79,3 +81,30 @@ func TestGroupDeleteRoute(t *testing.T) {
 
 	assert.True(t, reflect.TypeOf(found["DELETE/group/path"]).String() == "grinder.Route")
 }
+
+func TestGroupUse(t *testing.T) {
+	g := New()
+
+	order := []string{}
+	mw := func(name string) MiddlewareFunc {
+		return func(next Handler) Handler {
+			return func(c Context) error {
+				order = append(order, name)
+				return next(c)
+			}
+		}
+	}
+
+	g.Use(mw("grinder"))
+
+	group := g.Group("/group")
+	group.Use(mw("group"))
+	group.GET("/path", func(c Context) error {
+		return c.String(200, "This is a test")
+	})
+
+	r, _ := http.NewRequest("GET", "/group/path", nil)
+	g.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, []string{"grinder", "group"}, order)
+}
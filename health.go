@@ -0,0 +1,175 @@
+package grinder
+
+import (
+	gocontext "context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthCheck checks a dependency of the service, i.e. a database ping
+type HealthCheck struct {
+	Name     string
+	Check    func(gocontext.Context) error
+	Timeout  time.Duration // Defaults to 5 seconds, a check running longer fails
+	CacheFor time.Duration // Reuse the result for this long, 0 checks on every request
+
+	// Liveness also runs the check for /healthz. Checks are readiness only by
+	// default, a dependency being down should not get the process restarted.
+	Liveness bool
+}
+
+// HealthStatus is the JSON body of the health endpoints
+type HealthStatus struct {
+	Status  string                 `json:"status"` // ok, fail or draining
+	Name    string                 `json:"name,omitempty"`
+	Version string                 `json:"version,omitempty"`
+	Checks  map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult is the outcome of one HealthCheck
+type CheckResult struct {
+	Status   string `json:"status"` // ok or fail
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// DefaultHealthCheckTimeout is how long checks without a Timeout may run
+var DefaultHealthCheckTimeout = 5 * time.Second
+
+const (
+	healthOK       = "ok"
+	healthFail     = "fail"
+	healthDraining = "draining"
+)
+
+// healthCheck is a registered HealthCheck with its cached result
+type healthCheck struct {
+	HealthCheck
+
+	mu      sync.Mutex
+	checked time.Time
+	result  CheckResult
+}
+
+// AddHealthCheck registers a check reported by the health endpoints
+func (g *Grinder) AddHealthCheck(check HealthCheck) {
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultHealthCheckTimeout
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.checks = append(g.checks, &healthCheck{HealthCheck: check})
+}
+
+// Health registers the liveness endpoint prefix/healthz and the readiness
+// endpoint prefix/readyz. Both answer with a HealthStatus, 200 when healthy
+// and 503 otherwise. Readiness fails once shutdown starts, without running
+// the checks.
+func (g *Grinder) Health(prefix string, m ...Middleware) {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	g.GET(prefix+"/healthz", func(c Context) error {
+		return g.health(c, true)
+	}, m...)
+
+	g.GET(prefix+"/readyz", func(c Context) error {
+		if !g.Ready() {
+			return c.JSON(http.StatusServiceUnavailable, g.healthStatus(healthDraining, nil))
+		}
+
+		return g.health(c, false)
+	}, m...)
+}
+
+// health runs the checks for an endpoint in parallel and writes the result
+func (g *Grinder) health(c Context, liveness bool) error {
+	g.mu.Lock()
+	var checks []*healthCheck
+	for _, check := range g.checks {
+		if !liveness || check.Liveness {
+			checks = append(checks, check)
+		}
+	}
+	g.mu.Unlock()
+
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *healthCheck) {
+			defer wg.Done()
+			results[i] = check.run(c)
+		}(i, check)
+	}
+	wg.Wait()
+
+	status := healthOK
+	byName := make(map[string]CheckResult, len(checks))
+	for i, check := range checks {
+		byName[check.Name] = results[i]
+		if results[i].Status != healthOK {
+			status = healthFail
+		}
+	}
+
+	code := http.StatusOK
+	if status != healthOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	return c.JSON(code, g.healthStatus(status, byName))
+}
+
+func (g *Grinder) healthStatus(status string, checks map[string]CheckResult) HealthStatus {
+	return HealthStatus{
+		Status:  status,
+		Name:    g.Name(),
+		Version: g.Version(),
+		Checks:  checks,
+	}
+}
+
+// run checks, or returns the cached result while it is fresh
+func (h *healthCheck) run(ctx gocontext.Context) CheckResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.CacheFor > 0 && !h.checked.IsZero() && time.Since(h.checked) < h.CacheFor {
+		return h.result
+	}
+
+	// the result is shared by every probe, so it must not depend on whether
+	// the client which happened to run the check stayed connected
+	ctx, cancel := gocontext.WithTimeout(gocontext.WithoutCancel(ctx), h.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Check(ctx)
+	}()
+
+	// a check which ignores ctx is abandoned at the timeout
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: healthOK, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = healthFail
+		result.Error = err.Error()
+	}
+
+	h.result = result
+	h.checked = time.Now()
+
+	return result
+}
@@ -0,0 +1,143 @@
+package grinder
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func healthRequest(g *Grinder, path string) (int, HealthStatus) {
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+	var status HealthStatus
+	json.Unmarshal(rec.Body.Bytes(), &status)
+
+	return rec.Code, status
+}
+
+func TestHealth(t *testing.T) {
+	g := New()
+	g.Health("/")
+
+	var dbDown atomic.Bool
+	g.AddHealthCheck(HealthCheck{
+		Name: "db",
+		Check: func(ctx gocontext.Context) error {
+			if dbDown.Load() {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	})
+	g.AddHealthCheck(HealthCheck{
+		Name:     "disk",
+		Liveness: true,
+		Check: func(ctx gocontext.Context) error {
+			return nil
+		},
+	})
+
+	code, status := healthRequest(g, "/readyz")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "ok", status.Status)
+	assert.Equal(t, "ok", status.Checks["db"].Status)
+	assert.Equal(t, "ok", status.Checks["disk"].Status)
+
+	dbDown.Store(true)
+
+	code, status = healthRequest(g, "/readyz")
+	assert.Equal(t, 503, code)
+	assert.Equal(t, "fail", status.Status)
+	assert.Equal(t, "connection refused", status.Checks["db"].Error)
+
+	// liveness only runs liveness checks
+	code, status = healthRequest(g, "/healthz")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "ok", status.Status)
+	assert.NotContains(t, status.Checks, "db")
+	assert.Contains(t, status.Checks, "disk")
+}
+
+func TestHealthTimeoutAndCache(t *testing.T) {
+	g := New()
+	g.Health("/status")
+
+	var calls atomic.Int32
+	g.AddHealthCheck(HealthCheck{
+		Name:     "cached",
+		CacheFor: time.Minute,
+		Check: func(ctx gocontext.Context) error {
+			calls.Add(1)
+			return nil
+		},
+	})
+
+	block := make(chan struct{})
+	defer close(block)
+	g.AddHealthCheck(HealthCheck{
+		Name:    "slow",
+		Timeout: 10 * time.Millisecond,
+		Check: func(ctx gocontext.Context) error {
+			// ignores ctx, it is abandoned at the timeout
+			<-block
+			return nil
+		},
+	})
+
+	code, status := healthRequest(g, "/status/readyz")
+	assert.Equal(t, 503, code)
+	assert.Equal(t, "context deadline exceeded", status.Checks["slow"].Error)
+
+	healthRequest(g, "/status/readyz")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestHealthClientGone(t *testing.T) {
+	g := New()
+	g.Health("/status")
+
+	g.AddHealthCheck(HealthCheck{
+		Name:     "db",
+		CacheFor: time.Minute,
+		Check: func(ctx gocontext.Context) error {
+			return ctx.Err()
+		},
+	})
+
+	// the first prober hangs up, the cached result is not its cancellation
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/status/readyz", nil).WithContext(ctx))
+
+	code, status := healthRequest(g, "/status/readyz")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "ok", status.Checks["db"].Status)
+}
+
+func TestHealthDraining(t *testing.T) {
+	g := New()
+	g.Health("")
+	g.newServer(ServerConfig{Name: "orders", Version: "1.2.0"})
+
+	code, status := healthRequest(g, "/readyz")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "orders", status.Name)
+	assert.Equal(t, "1.2.0", status.Version)
+
+	assert.NoError(t, g.Shutdown(gocontext.Background()))
+
+	code, status = healthRequest(g, "/readyz")
+	assert.Equal(t, 503, code)
+	assert.Equal(t, "draining", status.Status)
+
+	// still alive while draining
+	code, _ = healthRequest(g, "/healthz")
+	assert.Equal(t, 200, code)
+}
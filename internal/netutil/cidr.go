@@ -0,0 +1,43 @@
+// Package netutil holds address parsing shared by grinder and its middleware
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseCIDRs parses CIDRs and single IPv4 or IPv6 addresses, i.e.
+// "10.0.0.0/8" or "::1", single addresses become a /32 or /128
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip or cidr %q", v)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip or cidr %q", v)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
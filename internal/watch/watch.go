@@ -0,0 +1,106 @@
+// Package watch notices changes to files by polling their modification
+// times, it is shared by everything grinder reloads while serving
+package watch
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Files watches a set of files for changes
+type Files struct {
+	files    []string
+	interval time.Duration
+
+	checked  atomic.Int64 // unix nanoseconds of the last change check
+	mu       sync.Mutex
+	modTimes []time.Time
+}
+
+// New records the current modification times of files, changes after it
+// returns are noticed
+func New(interval time.Duration, files ...string) *Files {
+	w := &Files{files: files, interval: interval}
+	w.modTimes = w.stat()
+	w.checked.Store(time.Now().UnixNano())
+
+	return w
+}
+
+// Poll calls fn when any file changed since the last check. Files are
+// checked at most once per interval by a single caller, the rest return at
+// once, so it is cheap enough to call on every request.
+func (w *Files) Poll(fn func()) {
+	now := time.Now().UnixNano()
+	last := w.checked.Load()
+
+	if now-last >= int64(w.interval) && w.checked.CompareAndSwap(last, now) {
+		w.check(fn)
+	}
+}
+
+// Watch checks the files every interval and calls fn when any changed,
+// until stop is called
+func (w *Files) Watch(fn func()) (stop func()) {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				w.check(fn)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func (w *Files) check(fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current := w.stat()
+	if equal(current, w.modTimes) {
+		return
+	}
+
+	// a file caught halfway through being replaced fails to load, it is
+	// retried once it changes again
+	w.modTimes = current
+	fn()
+}
+
+// stat returns the modification time of every file, zero for files which
+// do not exist
+func (w *Files) stat() []time.Time {
+	times := make([]time.Time, len(w.files))
+
+	for i, f := range w.files {
+		if info, err := os.Stat(f); err == nil {
+			times[i] = info.ModTime()
+		}
+	}
+
+	return times
+}
+
+func equal(a, b []time.Time) bool {
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
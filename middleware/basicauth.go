@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rinkbase/grinder"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BasicAuthValidator checks the credentials of a request
+type BasicAuthValidator func(user string, pass string, c grinder.Context) (bool, error)
+
+// BasicAuthConfig configuration for BasicAuth middleware
+type BasicAuthConfig struct {
+	Validator BasicAuthValidator
+	Realm     string // Defaults to Restricted
+}
+
+// DefaultBasicAuthConfig handles the default BasicAuth configuration for grinder
+var DefaultBasicAuthConfig = BasicAuthConfig{
+	Realm: "Restricted",
+}
+
+type basicAuthUserKey struct{}
+
+// BasicAuth protects routes with HTTP basic authentication checked by fn
+func BasicAuth(fn BasicAuthValidator) grinder.Middleware {
+	config := DefaultBasicAuthConfig
+	config.Validator = fn
+
+	return BasicAuthWithConfig(config)
+}
+
+// BasicAuthWithConfig returns a configured BasicAuth middleware. Missing or
+// invalid credentials get a 401 with a WWW-Authenticate challenge, the user of
+// valid ones is available through BasicAuthUser.
+func BasicAuthWithConfig(config BasicAuthConfig) grinder.Middleware {
+	if config.Validator == nil {
+		panic("grinder: basic auth middleware requires a validator")
+	}
+
+	if config.Realm == "" {
+		config.Realm = DefaultBasicAuthConfig.Realm
+	}
+
+	challenge := "Basic realm=" + strconv.Quote(config.Realm) + `, charset="UTF-8"`
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			if user, pass, ok := c.Request().BasicAuth(); ok {
+				valid, err := config.Validator(user, pass, c)
+				if err != nil {
+					return err
+				}
+
+				if valid {
+					return handler(c.WithContext(context.WithValue(c.Request().Context(), basicAuthUserKey{}, user)))
+				}
+			}
+
+			c.SetHeader("WWW-Authenticate", challenge)
+			return grinder.NewHTTPError(http.StatusUnauthorized)
+		}
+	}
+}
+
+// BasicAuthUser returns the user authenticated by the BasicAuth middleware
+func BasicAuthUser(ctx context.Context) string {
+	user, _ := ctx.Value(basicAuthUserKey{}).(string)
+	return user
+}
+
+// SecureCompare compares a and b in constant time, including when their
+// lengths differ
+func SecureCompare(a string, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// StaticBasicAuth returns a validator for a fixed set of user/password pairs
+func StaticBasicAuth(users map[string]string) BasicAuthValidator {
+	return func(user string, pass string, c grinder.Context) (bool, error) {
+		expected, ok := users[user]
+
+		// compare regardless so unknown users take as long as known ones
+		match := SecureCompare(pass, expected)
+
+		return ok && match, nil
+	}
+}
+
+// HtpasswdBasicAuth returns a validator for the users in an htpasswd file,
+// bcrypt ($2y$, $2a$, $2b$) and SHA ({SHA}) entries are supported
+func HtpasswdBasicAuth(path string) (BasicAuthValidator, error) {
+	users, err := readHtpasswd(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dummy, err := dummyHtpasswd(users)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(user string, pass string, c grinder.Context) (bool, error) {
+		hash, ok := users[user]
+		if !ok {
+			// check against a hash regardless so unknown users take as long as known ones
+			checkHtpasswd(dummy, pass)
+			return false, nil
+		}
+
+		return checkHtpasswd(hash, pass), nil
+	}, nil
+}
+
+// dummyHtpasswd returns a hash as costly to check as the file's entries, for
+// unknown users to be checked against
+func dummyHtpasswd(users map[string]string) (string, error) {
+	cost := 0
+	for _, hash := range users {
+		if c, err := bcrypt.Cost([]byte(hash)); err == nil && c > cost {
+			cost = c
+		}
+	}
+
+	// a file of SHA entries only is checked against one
+	if cost == 0 {
+		sum := sha1.Sum([]byte("grinder"))
+		return "{SHA}" + base64.StdEncoding.EncodeToString(sum[:]), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("grinder"), cost)
+	return string(hash), err
+}
+
+func readHtpasswd(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("htpasswd %s:%d: malformed entry", path, n)
+		}
+
+		if !supportedHtpasswd(parts[1]) {
+			return nil, fmt.Errorf("htpasswd %s:%d: unsupported hash for %s", path, n, parts[0])
+		}
+
+		users[parts[0]] = parts[1]
+	}
+
+	return users, scanner.Err()
+}
+
+func supportedHtpasswd(hash string) bool {
+	for _, prefix := range []string{"$2y$", "$2a$", "$2b$", "{SHA}"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkHtpasswd(hash string, pass string) bool {
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(pass))
+		expected := base64.StdEncoding.EncodeToString(sum[:])
+
+		return SecureCompare(hash[len("{SHA}"):], expected)
+	}
+
+	// htpasswd writes $2y$ which is the same algorithm as $2a$
+	if strings.HasPrefix(hash, "$2y$") {
+		hash = "$2a$" + hash[len("$2y$"):]
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+}
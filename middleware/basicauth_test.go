@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// credentials returns the header a client sends for user and pass
+func credentials(user string, pass string) map[string]string {
+	return map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))}
+}
+
+func TestBasicAuth(t *testing.T) {
+	m := BasicAuth(StaticBasicAuth(map[string]string{"admin": "secret"}))
+
+	var user string
+	rec := serve(m, func(c grinder.Context) error {
+		user = BasicAuthUser(c)
+		return c.Code(200)
+	}, "GET", "/", credentials("admin", "secret"))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "admin", user)
+
+	rec = serve(m, okHandler, "GET", "/", credentials("admin", "wrong"))
+	assert.Equal(t, 401, rec.Code)
+	assert.Equal(t, `Basic realm="Restricted", charset="UTF-8"`, rec.Header().Get("WWW-Authenticate"))
+
+	rec = serve(m, okHandler, "GET", "/", nil)
+	assert.Equal(t, 401, rec.Code)
+}
+
+func TestBasicAuthRealmAndErrors(t *testing.T) {
+	m := BasicAuthWithConfig(BasicAuthConfig{
+		Realm: "Admin",
+		Validator: func(user string, pass string, c grinder.Context) (bool, error) {
+			return false, errors.New("backend unavailable")
+		},
+	})
+
+	rec := serve(m, okHandler, "GET", "/", credentials("admin", "secret"))
+	assert.Equal(t, 500, rec.Code)
+
+	m = BasicAuthWithConfig(BasicAuthConfig{
+		Realm: "Admin",
+		Validator: func(user string, pass string, c grinder.Context) (bool, error) {
+			return false, nil
+		},
+	})
+
+	rec = serve(m, okHandler, "GET", "/", credentials("admin", "secret"))
+	assert.Equal(t, `Basic realm="Admin", charset="UTF-8"`, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestSecureCompare(t *testing.T) {
+	assert.True(t, SecureCompare("secret", "secret"))
+	assert.False(t, SecureCompare("secret", "secrets"))
+	assert.False(t, SecureCompare("", "secret"))
+}
+
+func TestHtpasswdBasicAuth(t *testing.T) {
+	bcrypted, _ := bcrypt.GenerateFromPassword([]byte("bcrypt-pass"), bcrypt.MinCost)
+	sum := sha1.Sum([]byte("sha-pass"))
+
+	file := strings.Join([]string{
+		"# internal tooling",
+		"alice:$2y$" + string(bcrypted)[4:],
+		"bob:{SHA}" + base64.StdEncoding.EncodeToString(sum[:]),
+	}, "\n")
+
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	os.WriteFile(path, []byte(file), 0600)
+
+	validator, err := HtpasswdBasicAuth(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	m := BasicAuth(validator)
+
+	rec := serve(m, okHandler, "GET", "/", credentials("alice", "bcrypt-pass"))
+	assert.Equal(t, 200, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", credentials("bob", "sha-pass"))
+	assert.Equal(t, 200, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", credentials("bob", "bcrypt-pass"))
+	assert.Equal(t, 401, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", credentials("carol", "sha-pass"))
+	assert.Equal(t, 401, rec.Code)
+}
+
+func TestHtpasswdDummyHash(t *testing.T) {
+	bcrypted, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.MinCost+1)
+
+	// unknown users are checked against a hash as costly as the real ones
+	dummy, err := dummyHtpasswd(map[string]string{"alice": "$2y$" + string(bcrypted)[4:], "bob": "{SHA}x"})
+	if assert.NoError(t, err) {
+		cost, _ := bcrypt.Cost([]byte(dummy))
+		assert.Equal(t, bcrypt.MinCost+1, cost)
+	}
+
+	dummy, err = dummyHtpasswd(map[string]string{"bob": "{SHA}x"})
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(dummy, "{SHA}"))
+	}
+}
+
+func TestHtpasswdUnsupportedHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	os.WriteFile(path, []byte("alice:$apr1$salt$hash\n"), 0600)
+
+	_, err := HtpasswdBasicAuth(path)
+	assert.Error(t, err)
+}
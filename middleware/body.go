@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rinkbase/grinder"
+)
+
+type bodyLimitKey struct{}
+
+// BodyLimit caps the request body at limit, i.e. "512KB" or "2MB". Larger
+// bodies get a 413, including ones which only grow that large once
+// decompressed by Decompress.
+func BodyLimit(limit string) grinder.Middleware {
+	n, err := parseBytes(limit)
+	if err != nil {
+		panic("grinder: " + err.Error())
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			req := c.Request()
+
+			if req.ContentLength > n {
+				return grinder.NewHTTPError(http.StatusRequestEntityTooLarge)
+			}
+
+			req.Body = http.MaxBytesReader(c.Response(), req.Body, n)
+
+			return handler(c.WithContext(context.WithValue(req.Context(), bodyLimitKey{}, n)))
+		}
+	}
+}
+
+// Decompress middleware transparently decodes gzip request bodies
+func Decompress(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+	return func(c grinder.Context) error {
+		req := c.Request()
+
+		switch strings.ToLower(req.Header.Get("Content-Encoding")) {
+		case "gzip", "x-gzip":
+		default:
+			return handler(c)
+		}
+
+		gr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return &grinder.HTTPError{
+				Code:    http.StatusBadRequest,
+				Message: http.StatusText(http.StatusBadRequest),
+				Inner:   err,
+			}
+		}
+		defer gr.Close()
+
+		body := io.ReadCloser(gr)
+
+		// an enclosing BodyLimit only saw the compressed size, apply it again
+		if n, ok := c.Value(bodyLimitKey{}).(int64); ok {
+			body = http.MaxBytesReader(c.Response(), body, n)
+		}
+
+		req.Body = body
+		req.ContentLength = -1
+		req.Header.Del("Content-Encoding")
+		req.Header.Del("Content-Length")
+
+		return handler(c)
+	}
+}
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseBytes parses sizes such as 2MB, 512K or 1024
+func parseBytes(s string) (int64, error) {
+	size := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+
+	for _, u := range byteUnits {
+		if strings.HasSuffix(size, u.suffix) {
+			size = strings.TrimSpace(strings.TrimSuffix(size, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(size, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid body limit %q", s)
+	}
+
+	return int64(n * float64(unit)), nil
+}
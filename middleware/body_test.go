@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipped(s string) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	w.Write([]byte(s))
+	w.Close()
+
+	return buf
+}
+
+func echoBody(c grinder.Context) error {
+	b, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	return c.String(200, string(b))
+}
+
+func TestBodyLimit(t *testing.T) {
+	g := grinder.New()
+	g.POST("/", echoBody, BodyLimit("1KB"))
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader("small")))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "small", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", 2048))))
+	assert.Equal(t, 413, rec.Code)
+
+	// without a content length the limit applies while reading
+	req := httptest.NewRequest("POST", "/", io.MultiReader(strings.NewReader(strings.Repeat("a", 2048))))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 413, rec.Code)
+}
+
+func TestBodyLimitForm(t *testing.T) {
+	g := grinder.New()
+
+	called := false
+	g.POST("/", func(c grinder.Context) error {
+		called = true
+		return c.String(200, c.GetParam("name"))
+	}, BodyLimit("64B"))
+
+	form := url.Values{"name": {"grinder"}}.Encode()
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, "grinder", rec.Body.String())
+
+	called = false
+	form = url.Values{"name": {strings.Repeat("a", 128)}}.Encode()
+	req = httptest.NewRequest("POST", "/", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 413, rec.Code)
+	assert.False(t, called)
+}
+
+func TestBodyLimitFormReadByMiddleware(t *testing.T) {
+	g := grinder.New()
+
+	var name string
+	readsForm := func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			name = c.GetParam("name")
+			return handler(c)
+		}
+	}
+
+	// route middleware is applied last to first, BodyLimit wraps readsForm
+	g.POST("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, readsForm, BodyLimit("64B"))
+
+	form := url.Values{"name": {strings.Repeat("a", 128)}}.Encode()
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 413, rec.Code)
+	assert.Empty(t, name)
+}
+
+func TestDecompress(t *testing.T) {
+	g := grinder.New()
+	g.POST("/", echoBody, Decompress)
+
+	req := httptest.NewRequest("POST", "/", gzipped("This is a test"))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, "This is a test", rec.Body.String())
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 400, rec.Code)
+
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader("plain")))
+	assert.Equal(t, "plain", rec.Body.String())
+}
+
+func TestDecompressBomb(t *testing.T) {
+	bomb := strings.Repeat("a", 1<<20)
+
+	// whichever way round they are registered the decompressed size is capped
+	for _, order := range [][]grinder.Middleware{
+		{Decompress, BodyLimit("1KB")},
+		{BodyLimit("1KB"), Decompress},
+	} {
+		g := grinder.New()
+		g.Before(order...)
+		g.POST("/", echoBody)
+
+		req := httptest.NewRequest("POST", "/", gzipped(bomb))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+
+		assert.Equal(t, 413, rec.Code)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"2MB":   2 << 20,
+		"512K":  512 << 10,
+		"1.5KB": 1536,
+		"100":   100,
+		"64B":   64,
+		"1 gb":  1 << 30,
+	} {
+		n, err := parseBytes(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, n, s)
+		}
+	}
+
+	_, err := parseBytes("lots")
+	assert.Error(t, err)
+
+	assert.Panics(t, func() {
+		BodyLimit("-1MB")
+	})
+}
@@ -0,0 +1,348 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rinkbase/grinder"
+)
+
+// CompressConfig configuration for Compress middleware
+type CompressConfig struct {
+	Level            int      // Passed to the encoder, i.e. 1 (fastest) to 9 (best) for gzip. 0 and -1 use its default
+	MinLength        int      // Bodies smaller than this are sent as is, defaults to 1024
+	Encodings        []string // Server preference, defaults to gzip, deflate (options: any registered encoding)
+	SkipContentTypes []string // Content type prefixes which are already compressed
+}
+
+// DefaultCompressConfig handles the default Compress configuration for grinder
+var DefaultCompressConfig = CompressConfig{
+	Level:     -1,
+	MinLength: 1024,
+	Encodings: []string{"gzip", "deflate"},
+	SkipContentTypes: []string{
+		"image/", "video/", "audio/", "font/woff",
+		"application/zip", "application/gzip", "application/x-gzip",
+		"application/x-7z-compressed", "application/x-rar-compressed",
+		"application/octet-stream",
+	},
+}
+
+// EncoderWriter is a compressing writer which can flush partial output
+type EncoderWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Encoder creates an EncoderWriter for a content coding writing to w
+type Encoder func(w io.Writer, level int) (EncoderWriter, error)
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{
+		"gzip": func(w io.Writer, level int) (EncoderWriter, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		// the deflate content coding is zlib wrapped, see RFC 9110 section 8.4.1.2
+		"deflate": func(w io.Writer, level int) (EncoderWriter, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	}
+)
+
+// RegisterEncoder makes a content coding, i.e. br or zstd, available to
+// Compress. List it in CompressConfig.Encodings to use it.
+func RegisterEncoder(name string, e Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	encoders[strings.ToLower(name)] = e
+}
+
+func lookupEncoder(name string) (Encoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	e, ok := encoders[name]
+	return e, ok
+}
+
+// Compress middleware gzip or deflate encodes responses
+func Compress(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+	return defaultCompress(ctx, handler)
+}
+
+var defaultCompress = CompressWithConfig(DefaultCompressConfig)
+
+// CompressWithConfig returns a configured Compress middleware. The encoding
+// is negotiated from Accept-Encoding, small bodies and content types in
+// SkipContentTypes are sent as is.
+func CompressWithConfig(config CompressConfig) grinder.Middleware {
+	// 0 is unset, leaving responses uncompressed would defeat the middleware
+	if config.Level == 0 {
+		config.Level = DefaultCompressConfig.Level
+	}
+
+	if config.MinLength <= 0 {
+		config.MinLength = DefaultCompressConfig.MinLength
+	}
+
+	if len(config.Encodings) == 0 {
+		config.Encodings = DefaultCompressConfig.Encodings
+	}
+
+	if config.SkipContentTypes == nil {
+		config.SkipContentTypes = DefaultCompressConfig.SkipContentTypes
+	}
+
+	// a level an encoder refuses would fail every response it encodes
+	for _, name := range config.Encodings {
+		if enc, ok := lookupEncoder(strings.ToLower(name)); ok {
+			w, err := enc(io.Discard, config.Level)
+			if err != nil {
+				panic(fmt.Sprintf("grinder: compression level %d for %s: %v", config.Level, name, err))
+			}
+			w.Close()
+		}
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			c.Response().Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"), config.Encodings)
+			if encoding == "" || c.Request().Method == http.MethodHead {
+				return handler(c)
+			}
+
+			encoder, ok := lookupEncoder(encoding)
+			if !ok {
+				return handler(c)
+			}
+
+			res := c.Response()
+			cw := &compressWriter{
+				w:        res,
+				encoding: encoding,
+				encoder:  encoder,
+				config:   &config,
+			}
+
+			c.SetResponse(grinder.NewResponse(cw))
+			defer c.SetResponse(res)
+
+			err := handler(c)
+			if cerr := cw.Close(); err == nil {
+				err = cerr
+			}
+
+			return err
+		}
+	}
+}
+
+// compressWriter buffers the start of the body until it knows whether it is
+// worth compressing, then either encodes or passes everything through
+type compressWriter struct {
+	w        http.ResponseWriter
+	encoding string
+	encoder  Encoder
+	config   *CompressConfig
+
+	code    int
+	buf     bytes.Buffer
+	decided bool
+	enc     EncoderWriter
+	err     error
+}
+
+func (cw *compressWriter) Header() http.Header {
+	return cw.w.Header()
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.code == 0 {
+		cw.code = code
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.code == 0 {
+		cw.code = http.StatusOK
+	}
+
+	if !cw.decided {
+		cw.buf.Write(b)
+		if cw.buf.Len() < cw.config.MinLength {
+			return len(b), nil
+		}
+
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+
+	return cw.w.Write(b)
+}
+
+// Flush sends what has been written so far, streamed responses are
+// compressed whatever their size
+func (cw *compressWriter) Flush() {
+	if cw.code == 0 {
+		cw.code = http.StatusOK
+	}
+
+	if !cw.decided {
+		if cw.err = cw.decide(true); cw.err != nil {
+			return
+		}
+	}
+
+	if cw.enc != nil {
+		if cw.err = cw.enc.Flush(); cw.err != nil {
+			return
+		}
+	}
+
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the body once the handler has returned
+func (cw *compressWriter) Close() error {
+	if cw.err != nil {
+		return cw.err
+	}
+
+	// nothing written, leave the response to whoever handles it next
+	if cw.code == 0 {
+		return nil
+	}
+
+	if !cw.decided {
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Close()
+	}
+
+	return nil
+}
+
+// decide writes the status and buffered body, compressing them when allowed
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	h := cw.w.Header()
+
+	if h.Get("Content-Type") == "" && cw.buf.Len() > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf.Bytes()))
+	}
+
+	if compress && cw.compressible() {
+		enc, err := cw.encoder(cw.w, cw.config.Level)
+		if err != nil {
+			return err
+		}
+
+		cw.enc = enc
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+	}
+
+	cw.w.WriteHeader(cw.code)
+
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.w.Write(cw.buf.Bytes())
+	}
+
+	cw.buf.Reset()
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	if cw.code < 200 || cw.code == http.StatusNoContent || cw.code == http.StatusNotModified {
+		return false
+	}
+
+	h := cw.w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	for _, skip := range cw.config.SkipContentTypes {
+		if strings.HasPrefix(contentType, skip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// negotiateEncoding picks the acceptable encoding with the highest q value,
+// ties going to the earliest in the server's preference
+func negotiateEncoding(header string, preference []string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if name != "" {
+			accepted[name] = q
+		}
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, name := range preference {
+		q, ok := accepted[name]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+
+		if ok && q > bestQ {
+			best = name
+			bestQ = q
+		}
+	}
+
+	return best
+}
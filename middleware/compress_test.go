@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+var large = strings.Repeat("This is a test. ", 200)
+
+func TestCompressGzip(t *testing.T) {
+	rec := serve(Compress, func(c grinder.Context) error {
+		c.SetHeader("Content-Length", "3200")
+		return c.String(200, large)
+	}, "GET", "/", map[string]string{"Accept-Encoding": "deflate;q=0.5, gzip"})
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+
+	r, err := gzip.NewReader(rec.Body)
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(r)
+		assert.Equal(t, large, string(body))
+	}
+}
+
+func TestCompressDeflate(t *testing.T) {
+	rec := serve(Compress, func(c grinder.Context) error {
+		return c.String(200, large)
+	}, "GET", "/", map[string]string{"Accept-Encoding": "gzip;q=0.1, deflate"})
+
+	assert.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+
+	zr, err := zlib.NewReader(rec.Body)
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(zr)
+		assert.Equal(t, large, string(body))
+	}
+}
+
+func TestCompressSkips(t *testing.T) {
+	small := func(c grinder.Context) error {
+		return c.String(200, "small")
+	}
+
+	rec := serve(Compress, small, "GET", "/", map[string]string{"Accept-Encoding": "gzip"})
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "small", rec.Body.String())
+
+	png := func(c grinder.Context) error {
+		c.SetHeader("Content-Type", "image/png")
+		c.Response().Write([]byte(large))
+		return nil
+	}
+
+	rec = serve(Compress, png, "GET", "/", map[string]string{"Accept-Encoding": "gzip"})
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, rec.Body.String())
+
+	rec = serve(Compress, small, "GET", "/", nil)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+
+	rec = serve(Compress, small, "GET", "/", map[string]string{"Accept-Encoding": "br"})
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+
+	rec = serve(Compress, func(c grinder.Context) error {
+		return c.Code(204)
+	}, "GET", "/", map[string]string{"Accept-Encoding": "gzip"})
+	assert.Equal(t, 204, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+}
+
+func TestCompressLevel(t *testing.T) {
+	rec := serve(CompressWithConfig(CompressConfig{Level: gzip.BestSpeed}), func(c grinder.Context) error {
+		return c.String(200, large)
+	}, "GET", "/", map[string]string{"Accept-Encoding": "gzip"})
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	// a level the encoder refuses fails at construction, not on every response
+	assert.Panics(t, func() {
+		CompressWithConfig(CompressConfig{Level: 12})
+	})
+}
+
+func TestCompressErrorPassesThrough(t *testing.T) {
+	rec := serve(Compress, func(c grinder.Context) error {
+		return grinder.NewHTTPError(http.StatusTeapot)
+	}, "GET", "/", map[string]string{"Accept-Encoding": "gzip"})
+
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+	assert.Equal(t, "\"I'm a teapot\"", rec.Body.String())
+}
+
+func TestCompressFlush(t *testing.T) {
+	g := grinder.New()
+	rec := httptest.NewRecorder()
+
+	g.GET("/", func(c grinder.Context) error {
+		c.SetHeader("Content-Type", "text/event-stream")
+		c.Response().Write([]byte("data: 1\n\n"))
+		c.Response().Flush()
+
+		// the first event is readable before the handler returns
+		r, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			return err
+		}
+
+		buf := make([]byte, 9)
+		if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "data: 1\n\n" {
+			return grinder.NewHTTPError(500)
+		}
+
+		c.Response().Write([]byte("data: 2\n\n"))
+		return nil
+	}, Compress)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	r, err := gzip.NewReader(rec.Body)
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(r)
+		assert.Equal(t, "data: 1\n\ndata: 2\n\n", string(body))
+	}
+}
+
+type upperEncoder struct {
+	w io.Writer
+}
+
+func (u *upperEncoder) Write(b []byte) (int, error) {
+	return u.w.Write(bytes.ToUpper(b))
+}
+
+func (u *upperEncoder) Flush() error {
+	return nil
+}
+
+func (u *upperEncoder) Close() error {
+	return nil
+}
+
+func TestCompressRegisteredEncoder(t *testing.T) {
+	RegisterEncoder("upper", func(w io.Writer, level int) (EncoderWriter, error) {
+		return &upperEncoder{w}, nil
+	})
+
+	m := CompressWithConfig(CompressConfig{Encodings: []string{"upper", "gzip"}, MinLength: 1})
+
+	rec := serve(m, func(c grinder.Context) error {
+		return c.String(200, "shout")
+	}, "GET", "/", map[string]string{"Accept-Encoding": "gzip, upper"})
+
+	assert.Equal(t, "upper", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "SHOUT", rec.Body.String())
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	preference := []string{"gzip", "deflate"}
+
+	assert.Equal(t, "gzip", negotiateEncoding("gzip, deflate", preference))
+	assert.Equal(t, "deflate", negotiateEncoding("gzip;q=0.5, deflate", preference))
+	assert.Equal(t, "gzip", negotiateEncoding("*", preference))
+	assert.Equal(t, "deflate", negotiateEncoding("*, gzip;q=0", preference))
+	assert.Equal(t, "", negotiateEncoding("identity", preference))
+}
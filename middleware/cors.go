@@ -3,15 +3,19 @@ package middleware
 import (
 	"github.com/rinkbase/grinder"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
 // CORSConfig configuration for CORS middleware
 type CORSConfig struct {
-	AllowedOrigins []string `json:"allowed_origins"`
-	AllowedMethods []string `json:"allowed_methods"`
-	AllowedHeaders []string `json:"allowed_headers"`
-	ExposedHeaders []string `json:"exposed_headers"`
+	AllowedOrigins   []string                 `json:"allowed_origins"` // Exact origins, "*", or wildcard subdomains like https://*.example.com
+	AllowOriginFunc  func(origin string) bool `json:"-"`               // Allows any origin it returns true for, in addition to AllowedOrigins
+	AllowedMethods   []string                 `json:"allowed_methods"`
+	AllowedHeaders   []string                 `json:"allowed_headers"`
+	ExposedHeaders   []string                 `json:"exposed_headers"`
+	AllowCredentials bool                     `json:"allow_credentials"`
+	MaxAge           int                      `json:"max_age"` // Seconds preflight responses may be cached, 0 leaves it to the browser
 }
 
 // DefaultCORSConfig handles the default CORS configuration for grinder
@@ -29,12 +33,23 @@ func CORSError(ctx grinder.Context) error {
 
 // CORS middleware for Grinder routes
 func CORS(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
-	return CORSConfigured(ctx, handler, DefaultCORSConfig)
+	return defaultCORS(ctx, handler)
 }
 
-// CORSConfigured returns a configured CORS middleware
+var defaultCORS = CORSWithConfig(DefaultCORSConfig)
+
+// CORSConfigured returns a configured CORS middleware, the config is processed
+// on every call so prefer CORSWithConfig
 func CORSConfigured(ctx grinder.Context, handler grinder.Handler, config CORSConfig) grinder.Handler {
-	if len(config.AllowedOrigins) == 0 {
+	return CORSWithConfig(config)(ctx, handler)
+}
+
+// CORSWithConfig returns a configured CORS middleware. Requests from origins
+// which are not allowed get a 403 without the handler being called, allowed
+// origins are echoed back one at a time as browsers expect. AllowCredentials
+// can't be combined with the "*" origin.
+func CORSWithConfig(config CORSConfig) grinder.Middleware {
+	if len(config.AllowedOrigins) == 0 && config.AllowOriginFunc == nil {
 		config.AllowedOrigins = DefaultCORSConfig.AllowedOrigins
 	}
 
@@ -50,32 +65,155 @@ func CORSConfigured(ctx grinder.Context, handler grinder.Handler, config CORSCon
 		config.ExposedHeaders = DefaultCORSConfig.ExposedHeaders
 	}
 
-	allowedOrigins := strings.Join(config.AllowedOrigins, ",")
+	origins := newOriginMatcher(config.AllowedOrigins, config.AllowOriginFunc)
+
+	// echoing every origin with credentials lets any site make credentialed reads
+	if origins.any && config.AllowCredentials {
+		panic("grinder: cors credentials require explicit origins or an AllowOriginFunc, not *")
+	}
+
+	methods := make(map[string]bool)
+	for _, m := range config.AllowedMethods {
+		methods[strings.ToUpper(m)] = true
+	}
+
+	anyHeader := false
+	headers := make(map[string]bool)
+	for _, h := range config.AllowedHeaders {
+		if h == "*" {
+			anyHeader = true
+		}
+		headers[http.CanonicalHeaderKey(h)] = true
+	}
+
 	allowedMethods := strings.Join(config.AllowedMethods, ",")
 	allowedHeaders := strings.Join(config.AllowedHeaders, ",")
 	exposedHeaders := strings.Join(config.ExposedHeaders, ",")
+	maxAge := strconv.Itoa(config.MaxAge)
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(ctx grinder.Context) error {
+			req := ctx.Request()
+			res := ctx.Response()
 
-	return func(ctx grinder.Context) error {
-		req := ctx.Request()
-		res := ctx.Response()
+			origin := req.Header.Get("Origin")
+			requestMethod := req.Header.Get("Access-Control-Request-Method")
+			preflight := req.Method == http.MethodOptions && requestMethod != ""
 
-		if req.Method != "OPTIONS" {
 			res.Header().Add("Vary", "Origin")
-			res.Header().Set("Access-Control-Allow-Origin", allowedOrigins)
-			if exposedHeaders != "" {
-				res.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+
+			// not a cross origin request
+			if origin == "" {
+				return handler(ctx)
+			}
+
+			if !origins.match(origin) {
+				return grinder.NewHTTPError(http.StatusForbidden, "CORS origin not allowed")
+			}
+
+			allowOrigin := origin
+			if origins.any {
+				allowOrigin = "*"
+			}
+
+			if !preflight {
+				res.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+				if config.AllowCredentials {
+					res.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+				if exposedHeaders != "" {
+					res.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+				}
+
+				return handler(ctx)
+			}
+
+			res.Header().Add("Vary", "Access-Control-Request-Method")
+			res.Header().Add("Vary", "Access-Control-Request-Headers")
+
+			if !methods[strings.ToUpper(requestMethod)] {
+				return grinder.NewHTTPError(http.StatusForbidden, "CORS method not allowed")
+			}
+
+			requestHeaders := req.Header.Get("Access-Control-Request-Headers")
+			if !anyHeader {
+				for _, h := range strings.Split(requestHeaders, ",") {
+					h = strings.TrimSpace(h)
+					if h != "" && !headers[http.CanonicalHeaderKey(h)] {
+						return grinder.NewHTTPError(http.StatusForbidden, "CORS header not allowed")
+					}
+				}
 			}
 
-			return handler(ctx)
+			res.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			res.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+
+			// the wildcard is taken literally for credentialed requests
+			if anyHeader && config.AllowCredentials && requestHeaders != "" {
+				res.Header().Set("Access-Control-Allow-Headers", requestHeaders)
+			} else {
+				res.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			}
+
+			if config.AllowCredentials {
+				res.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if config.MaxAge > 0 {
+				res.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+
+			return ctx.Code(http.StatusNoContent)
+		}
+	}
+}
+
+// originMatcher matches request origins against exact origins, wildcard
+// subdomains and an optional predicate
+type originMatcher struct {
+	any       bool
+	exact     map[string]bool
+	wildcards [][2]string
+	fn        func(string) bool
+}
+
+func newOriginMatcher(origins []string, fn func(string) bool) *originMatcher {
+	m := &originMatcher{exact: make(map[string]bool), fn: fn}
+
+	for _, o := range origins {
+		o = strings.ToLower(o)
+
+		switch {
+		case o == "*":
+			m.any = true
+		case strings.Contains(o, "*"):
+			i := strings.Index(o, "*")
+			m.wildcards = append(m.wildcards, [2]string{o[:i], o[i+1:]})
+		default:
+			m.exact[o] = true
 		}
+	}
+
+	return m
+}
 
-		res.Header().Add("Vary", "Origin")
-		res.Header().Add("Vary", "Access-Control-Request-Method")
-		res.Header().Add("Vary", "Access-Control-Request-Headers")
-		res.Header().Set("Access-Control-Allow-Origin", allowedOrigins)
-		res.Header().Set("Access-Control-Allow-Methods", allowedMethods)
-		res.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+func (m *originMatcher) match(origin string) bool {
+	o := strings.ToLower(origin)
 
-		return ctx.Code(http.StatusNoContent)
+	if m.any || m.exact[o] {
+		return true
 	}
+
+	for _, w := range m.wildcards {
+		prefix, suffix := w[0], w[1]
+		if len(o) <= len(prefix)+len(suffix) || !strings.HasPrefix(o, prefix) || !strings.HasSuffix(o, suffix) {
+			continue
+		}
+
+		if sub := o[len(prefix) : len(o)-len(suffix)]; !strings.ContainsAny(sub, "/:") {
+			return true
+		}
+	}
+
+	return m.fn != nil && m.fn(origin)
 }
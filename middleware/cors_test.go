@@ -19,3 +19,128 @@ func TestCORS(t *testing.T) {
 
 	assert.True(t, reflect.TypeOf(nfh).String() == "*grinder.HTTPError")
 }
+
+func TestCORSEchoesSingleOrigin(t *testing.T) {
+	config := CORSConfig{AllowedOrigins: []string{"https://a.com", "https://b.com"}}
+	m := CORSWithConfig(config)
+
+	rec := serve(m, okHandler, "GET", "/", map[string]string{"Origin": "https://b.com"})
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "https://b.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", rec.Header().Get("Vary"))
+}
+
+func TestCORSRejectsDisallowedOrigin(t *testing.T) {
+	config := CORSConfig{AllowedOrigins: []string{"https://a.com"}}
+	m := CORSWithConfig(config)
+
+	rec := serve(m, okHandler, "GET", "/", map[string]string{"Origin": "https://evil.com"})
+
+	assert.Equal(t, 403, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSWithoutOrigin(t *testing.T) {
+	config := CORSConfig{AllowedOrigins: []string{"https://a.com"}}
+	m := CORSWithConfig(config)
+
+	rec := serve(m, okHandler, "GET", "/", nil)
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSWildcardSubdomain(t *testing.T) {
+	config := CORSConfig{AllowedOrigins: []string{"https://*.example.com"}}
+	m := CORSWithConfig(config)
+
+	rec := serve(m, okHandler, "GET", "/", map[string]string{"Origin": "https://api.example.com"})
+	assert.Equal(t, 200, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", map[string]string{"Origin": "https://example.com"})
+	assert.Equal(t, 403, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", map[string]string{"Origin": "http://api.example.com"})
+	assert.Equal(t, 403, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", map[string]string{"Origin": "https://evil.com/.example.com"})
+	assert.Equal(t, 403, rec.Code)
+}
+
+func TestCORSOriginFunc(t *testing.T) {
+	config := CORSConfig{AllowOriginFunc: func(origin string) bool {
+		return origin == "https://a.com"
+	}}
+	m := CORSWithConfig(config)
+
+	rec := serve(m, okHandler, "GET", "/", map[string]string{"Origin": "https://a.com"})
+	assert.Equal(t, 200, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", map[string]string{"Origin": "https://b.com"})
+	assert.Equal(t, 403, rec.Code)
+}
+
+func TestCORSCredentials(t *testing.T) {
+	config := CORSConfig{AllowedOrigins: []string{"https://a.com"}, AllowCredentials: true}
+	m := CORSWithConfig(config)
+
+	rec := serve(m, okHandler, "GET", "/", map[string]string{"Origin": "https://a.com"})
+
+	assert.Equal(t, "https://a.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+
+	rec = serve(CORSWithConfig(DefaultCORSConfig), okHandler, "GET", "/", map[string]string{"Origin": "https://a.com"})
+
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+
+	// any origin with credentials would let every site read responses as the user
+	assert.Panics(t, func() {
+		CORSWithConfig(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	})
+	assert.Panics(t, func() {
+		CORSWithConfig(CORSConfig{AllowCredentials: true})
+	})
+}
+
+func TestCORSPreflight(t *testing.T) {
+	config := CORSConfig{
+		AllowedOrigins: []string{"https://a.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         600,
+	}
+	m := CORSWithConfig(config)
+
+	rec := serve(m, okHandler, "OPTIONS", "/", map[string]string{
+		"Origin":                         "https://a.com",
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type, authorization",
+	})
+
+	assert.Equal(t, 204, rec.Code)
+	assert.Equal(t, "https://a.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET,POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+
+	rec = serve(m, okHandler, "OPTIONS", "/", map[string]string{
+		"Origin":                        "https://a.com",
+		"Access-Control-Request-Method": "DELETE",
+	})
+
+	assert.Equal(t, 403, rec.Code)
+
+	rec = serve(m, okHandler, "OPTIONS", "/", map[string]string{
+		"Origin":                         "https://a.com",
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "X-Custom",
+	})
+
+	assert.Equal(t, 403, rec.Code)
+
+	// plain OPTIONS requests are not preflights
+	rec = serve(m, okHandler, "OPTIONS", "/", map[string]string{"Origin": "https://a.com"})
+
+	assert.Equal(t, 200, rec.Code)
+}
@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rinkbase/grinder"
+)
+
+// CSRFConfig configuration for CSRF middleware
+type CSRFConfig struct {
+	TokenLookup    string        // Defaults to header:X-CSRF-Token (options: header:<name>, form:<name>, comma separated)
+	CookieName     string        // Defaults to _csrf
+	CookiePath     string        // Defaults to /
+	CookieDomain   string        //
+	CookieMaxAge   int           // Seconds, defaults to 86400
+	CookieSecure   bool          //
+	CookieHTTPOnly bool          // Leave off for double submit cookies read by javascript
+	CookieSameSite http.SameSite // Defaults to Lax (options: Lax, Strict, None)
+
+	// Store switches from double submit cookies to synchronizer tokens, kept
+	// server side for the session Session returns
+	Store   CSRFStore
+	Session func(grinder.Context) (string, error)
+}
+
+// CSRFStore keeps synchronizer tokens per session
+type CSRFStore interface {
+	Get(session string) (string, error) // Returns "" when the session has no token
+	Set(session string, token string) error
+}
+
+// DefaultCSRFConfig handles the default CSRF configuration for grinder
+var DefaultCSRFConfig = CSRFConfig{
+	TokenLookup:    "header:X-CSRF-Token",
+	CookieName:     "_csrf",
+	CookiePath:     "/",
+	CookieMaxAge:   86400,
+	CookieSameSite: http.SameSiteLaxMode,
+}
+
+var (
+	// ErrCSRFMissing results in a 400 when an unsafe request has no token
+	ErrCSRFMissing = errors.New("CSRF token is missing")
+
+	// ErrCSRFInvalid results in a 403 when the token does not match
+	ErrCSRFInvalid = errors.New("CSRF token is invalid")
+)
+
+type csrfTokenKey struct{}
+
+// CSRF middleware protects unsafe methods with double submit cookies, the
+// token is expected in the X-CSRF-Token header
+func CSRF(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+	return defaultCSRF(ctx, handler)
+}
+
+var defaultCSRF = CSRFWithConfig(DefaultCSRFConfig)
+
+// CSRFWithConfig returns a configured CSRF middleware. GET, HEAD, OPTIONS
+// and TRACE requests pass, every other method must send the request's token
+// which handlers get from CSRFToken to embed in forms.
+func CSRFWithConfig(config CSRFConfig) grinder.Middleware {
+	if config.TokenLookup == "" {
+		config.TokenLookup = DefaultCSRFConfig.TokenLookup
+	}
+
+	if config.CookieName == "" {
+		config.CookieName = DefaultCSRFConfig.CookieName
+	}
+
+	if config.CookiePath == "" {
+		config.CookiePath = DefaultCSRFConfig.CookiePath
+	}
+
+	if config.CookieMaxAge == 0 {
+		config.CookieMaxAge = DefaultCSRFConfig.CookieMaxAge
+	}
+
+	if config.CookieSameSite == 0 {
+		config.CookieSameSite = DefaultCSRFConfig.CookieSameSite
+	}
+
+	if config.Store != nil && config.Session == nil {
+		panic("grinder: csrf middleware requires a session func with a store")
+	}
+
+	parsers, err := csrfParsers(config.TokenLookup)
+	if err != nil {
+		panic("grinder: " + err.Error())
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			token, err := csrfToken(c, &config)
+			if err != nil {
+				return err
+			}
+
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			default:
+				sent := ""
+				for _, parser := range parsers {
+					if t, err := parser(c); err == nil {
+						sent = t
+						break
+					}
+				}
+
+				if sent == "" {
+					return csrfError(http.StatusBadRequest, ErrCSRFMissing)
+				}
+
+				if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+					return csrfError(http.StatusForbidden, ErrCSRFInvalid)
+				}
+			}
+
+			c.Response().Header().Add("Vary", "Cookie")
+
+			return handler(c.WithContext(context.WithValue(c.Request().Context(), csrfTokenKey{}, token)))
+		}
+	}
+}
+
+// CSRFToken returns the token of the request, for forms and meta tags
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// csrfToken loads the token for the request, creating one when there is none
+func csrfToken(c grinder.Context, config *CSRFConfig) (string, error) {
+	if config.Store != nil {
+		session, err := config.Session(c)
+		if err != nil {
+			return "", err
+		}
+
+		token, err := config.Store.Get(session)
+		if err != nil || token != "" {
+			return token, err
+		}
+
+		token = newCSRFToken()
+		return token, config.Store.Set(session, token)
+	}
+
+	token := ""
+	if cookie, err := c.Request().Cookie(config.CookieName); err == nil {
+		token = cookie.Value
+	}
+
+	if token == "" {
+		token = newCSRFToken()
+	}
+
+	http.SetCookie(c.Response(), &http.Cookie{
+		Name:     config.CookieName,
+		Value:    token,
+		Path:     config.CookiePath,
+		Domain:   config.CookieDomain,
+		MaxAge:   config.CookieMaxAge,
+		Secure:   config.CookieSecure,
+		HttpOnly: config.CookieHTTPOnly,
+		SameSite: config.CookieSameSite,
+	})
+
+	return token, nil
+}
+
+// MemoryCSRFStore is an in-memory CSRFStore, tokens are local to the process
+type MemoryCSRFStore struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+// NewMemoryCSRFStore creates an empty MemoryCSRFStore
+func NewMemoryCSRFStore() *MemoryCSRFStore {
+	return &MemoryCSRFStore{tokens: make(map[string]string)}
+}
+
+// Get implements CSRFStore
+func (s *MemoryCSRFStore) Get(session string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.tokens[session], nil
+}
+
+// Set implements CSRFStore
+func (s *MemoryCSRFStore) Set(session string, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[session] = token
+	return nil
+}
+
+// Delete forgets the token of a session, i.e. on logout
+func (s *MemoryCSRFStore) Delete(session string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, session)
+}
+
+func csrfParsers(lookup string) ([]TokenParser, error) {
+	var parsers []TokenParser
+
+	for _, source := range strings.Split(lookup, ",") {
+		parts := strings.SplitN(strings.TrimSpace(source), ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid csrf token lookup %q", source)
+		}
+
+		switch parts[0] {
+		case "header":
+			parsers = append(parsers, parseFromHeaderName(parts[1], "", ErrCSRFMissing))
+		case "form":
+			parsers = append(parsers, parseFromForm(parts[1], ErrCSRFMissing))
+		default:
+			return nil, fmt.Errorf("invalid csrf token lookup %q", source)
+		}
+	}
+
+	return parsers, nil
+}
+
+// expects <name>=<HASH> in the request body
+func parseFromForm(name string, missing error) TokenParser {
+	return func(c grinder.Context) (string, error) {
+		token := c.Request().PostFormValue(name)
+		if token == "" {
+			return "", missing
+		}
+
+		return token, nil
+	}
+}
+
+func csrfError(code int, err error) error {
+	return &grinder.HTTPError{
+		Code:    code,
+		Message: http.StatusText(code),
+		Inner:   err,
+	}
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func csrfService(m grinder.Middleware, token *string) *grinder.Grinder {
+	g := grinder.New()
+	g.Before(m)
+	g.GET("/", func(c grinder.Context) error {
+		*token = CSRFToken(c)
+		return c.Code(200)
+	})
+	g.POST("/", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	return g
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	var token string
+	g := csrfService(CSRF, &token)
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, 200, rec.Code)
+	assert.NotEmpty(t, token)
+
+	cookie := rec.Result().Cookies()[0]
+	assert.Equal(t, "_csrf", cookie.Name)
+	assert.Equal(t, token, cookie.Value)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	// missing token
+	req := httptest.NewRequest("POST", "/", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 400, rec.Code)
+
+	// wrong token
+	req = httptest.NewRequest("POST", "/", nil)
+	req.AddCookie(cookie)
+	req.Header.Set("X-CSRF-Token", "nope")
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 403, rec.Code)
+
+	// no cookie, a token alone is not enough
+	req = httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("X-CSRF-Token", token)
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 403, rec.Code)
+
+	req = httptest.NewRequest("POST", "/", nil)
+	req.AddCookie(cookie)
+	req.Header.Set("X-CSRF-Token", token)
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 200, rec.Code)
+}
+
+func TestCSRFFormLookup(t *testing.T) {
+	var token string
+	g := csrfService(CSRFWithConfig(CSRFConfig{
+		TokenLookup:    "form:csrf,header:X-CSRF-Token",
+		CookieHTTPOnly: true,
+		CookieSameSite: http.SameSiteStrictMode,
+	}), &token)
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	cookie := rec.Result().Cookies()[0]
+
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+
+	body := url.Values{"csrf": {token}}.Encode()
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 200, rec.Code)
+}
+
+func TestCSRFSynchronizer(t *testing.T) {
+	store := NewMemoryCSRFStore()
+
+	var token string
+	g := csrfService(CSRFWithConfig(CSRFConfig{
+		Store: store,
+		Session: func(c grinder.Context) (string, error) {
+			return c.GetHeader("X-Session"), nil
+		},
+	}), &token)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Session", "alice")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Empty(t, rec.Result().Cookies())
+	stored, _ := store.Get("alice")
+	assert.Equal(t, token, stored)
+
+	// another session's token is rejected
+	req = httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("X-Session", "bob")
+	req.Header.Set("X-CSRF-Token", token)
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 403, rec.Code)
+
+	req = httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("X-Session", "alice")
+	req.Header.Set("X-CSRF-Token", token)
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 200, rec.Code)
+}
+
+func TestCSRFInvalidLookup(t *testing.T) {
+	assert.Panics(t, func() {
+		CSRFWithConfig(CSRFConfig{TokenLookup: "cookie:csrf"})
+	})
+}
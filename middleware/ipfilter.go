@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rinkbase/grinder"
+	"github.com/rinkbase/grinder/internal/netutil"
+	"github.com/rinkbase/grinder/internal/watch"
+)
+
+// IPFilterConfig configuration for IPFilter middleware
+type IPFilterConfig struct {
+	Allow []string // CIDRs or addresses, when set every other client is refused
+	Deny  []string // CIDRs or addresses refused even when allowed
+
+	// List replaces Allow and Deny with rules which can be updated while
+	// serving, i.e. from config
+	List *IPList
+
+	// File adds rules from a file, one per line as "allow <cidr>" or
+	// "deny <cidr>" with # comments. Changes are picked up every
+	// ReloadInterval, a file which fails to parse keeps the rules it had.
+	File           string
+	ReloadInterval time.Duration // Defaults to 10 seconds
+}
+
+// DefaultIPFilterConfig handles the default IPFilter configuration for grinder
+var DefaultIPFilterConfig = IPFilterConfig{
+	ReloadInterval: 10 * time.Second,
+}
+
+// IPFilter only lets clients within the allowed CIDRs through
+func IPFilter(allow ...string) grinder.Middleware {
+	return IPFilterWithConfig(IPFilterConfig{Allow: allow})
+}
+
+// IPFilterWithConfig returns a configured IPFilter middleware. Clients are
+// matched by RealIP, see Grinder.TrustProxies, and refused with a 403.
+func IPFilterWithConfig(config IPFilterConfig) grinder.Middleware {
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = DefaultIPFilterConfig.ReloadInterval
+	}
+
+	list := config.List
+	if list == nil {
+		var err error
+		if list, err = NewIPList(config.Allow, config.Deny); err != nil {
+			panic("grinder: " + err.Error())
+		}
+	}
+
+	var file *ipFile
+	if config.File != "" {
+		file = &ipFile{path: config.File, files: watch.New(config.ReloadInterval, config.File)}
+		if err := file.load(); err != nil {
+			panic("grinder: " + err.Error())
+		}
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			rules := []*ipRules{list.rules.Load()}
+			if file != nil {
+				rules = append(rules, file.current())
+			}
+
+			if !ipAllowed(net.ParseIP(c.RealIP()), rules...) {
+				return grinder.NewHTTPError(http.StatusForbidden)
+			}
+
+			return handler(c)
+		}
+	}
+}
+
+// IPList is a set of allow and deny rules, safe to update while in use
+type IPList struct {
+	rules atomic.Pointer[ipRules]
+}
+
+type ipRules struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPList creates an IPList, an empty allow list allows every client not
+// denied
+func NewIPList(allow []string, deny []string) (*IPList, error) {
+	l := &IPList{}
+	if err := l.Set(allow, deny); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Set replaces the rules, they are left unchanged when any is invalid
+func (l *IPList) Set(allow []string, deny []string) error {
+	a, err := netutil.ParseCIDRs(allow)
+	if err != nil {
+		return err
+	}
+
+	d, err := netutil.ParseCIDRs(deny)
+	if err != nil {
+		return err
+	}
+
+	l.rules.Store(&ipRules{allow: a, deny: d})
+	return nil
+}
+
+// Allowed reports whether ip passes the rules, deny taking precedence
+func (l *IPList) Allowed(ip net.IP) bool {
+	return ipAllowed(ip, l.rules.Load())
+}
+
+// ipAllowed checks ip against several sets of rules as if they were one
+func ipAllowed(ip net.IP, rules ...*ipRules) bool {
+	if ip == nil {
+		return false
+	}
+
+	allowList := false
+	for _, r := range rules {
+		for _, n := range r.deny {
+			if n.Contains(ip) {
+				return false
+			}
+		}
+
+		allowList = allowList || len(r.allow) > 0
+	}
+
+	if !allowList {
+		return true
+	}
+
+	for _, r := range rules {
+		for _, n := range r.allow {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ipFile holds the rules of a file, reloading it when it changes
+type ipFile struct {
+	path  string
+	files *watch.Files
+	rules atomic.Pointer[ipRules]
+}
+
+func (f *ipFile) current() *ipRules {
+	// a file which fails to parse keeps the rules it had
+	f.files.Poll(func() {
+		if err := f.load(); err != nil {
+			log.Println(err)
+		}
+	})
+
+	return f.rules.Load()
+}
+
+func (f *ipFile) load() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rules := &ipRules{}
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: invalid ip filter rule %q", f.path, n, line)
+		}
+
+		nets, err := netutil.ParseCIDRs(fields[1:])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", f.path, n, err)
+		}
+
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			rules.allow = append(rules.allow, nets...)
+		case "deny":
+			rules.deny = append(rules.deny, nets...)
+		default:
+			return fmt.Errorf("%s:%d: invalid ip filter rule %q", f.path, n, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	f.rules.Store(rules)
+
+	return nil
+}
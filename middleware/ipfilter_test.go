@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func ipFilterStatus(g *grinder.Grinder, path string, addr string) int {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = addr
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	return rec.Code
+}
+
+func TestIPFilterGroup(t *testing.T) {
+	g := grinder.New()
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	admin := g.Group("/admin", IPFilterWithConfig(IPFilterConfig{
+		Allow: []string{"10.0.0.0/8", "2001:db8::/32"},
+		Deny:  []string{"10.6.6.6"},
+	}))
+	admin.GET("/users", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	assert.Equal(t, 200, ipFilterStatus(g, "/", "203.0.113.1:1234"))
+	assert.Equal(t, 403, ipFilterStatus(g, "/admin/users", "203.0.113.1:1234"))
+	assert.Equal(t, 200, ipFilterStatus(g, "/admin/users", "10.1.2.3:1234"))
+	assert.Equal(t, 200, ipFilterStatus(g, "/admin/users", "[2001:db8::5]:1234"))
+	assert.Equal(t, 403, ipFilterStatus(g, "/admin/users", "10.6.6.6:1234"))
+}
+
+func TestIPFilterRealIP(t *testing.T) {
+	g := grinder.New()
+	g.TrustProxies("10.0.0.0/8")
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, IPFilter("192.168.1.0/24"))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "192.168.1.20")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, 403, ipFilterStatus(g, "/", "10.0.0.1:1234"))
+}
+
+func TestIPFilterList(t *testing.T) {
+	list, err := NewIPList(nil, []string{"203.0.113.0/24"})
+	assert.NoError(t, err)
+
+	g := grinder.New()
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, IPFilterWithConfig(IPFilterConfig{List: list}))
+
+	assert.Equal(t, 403, ipFilterStatus(g, "/", "203.0.113.1:1234"))
+	assert.Equal(t, 200, ipFilterStatus(g, "/", "198.51.100.1:1234"))
+
+	assert.Error(t, list.Set([]string{"nope"}, nil))
+	assert.NoError(t, list.Set([]string{"203.0.113.0/24"}, nil))
+
+	assert.Equal(t, 200, ipFilterStatus(g, "/", "203.0.113.1:1234"))
+	assert.Equal(t, 403, ipFilterStatus(g, "/", "198.51.100.1:1234"))
+}
+
+func TestIPFilterFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ips")
+	os.WriteFile(path, []byte("# office\nallow 192.0.2.0/24\n"), 0644)
+
+	g := grinder.New()
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, IPFilterWithConfig(IPFilterConfig{File: path, ReloadInterval: time.Nanosecond}))
+
+	assert.Equal(t, 200, ipFilterStatus(g, "/", "192.0.2.1:1234"))
+	assert.Equal(t, 403, ipFilterStatus(g, "/", "198.51.100.1:1234"))
+
+	later := time.Now().Add(time.Minute)
+
+	os.WriteFile(path, []byte("allow 198.51.100.0/24 # vpn\n"), 0644)
+	os.Chtimes(path, later, later)
+
+	assert.Equal(t, 403, ipFilterStatus(g, "/", "192.0.2.1:1234"))
+	assert.Equal(t, 200, ipFilterStatus(g, "/", "198.51.100.1:1234"))
+
+	// a broken file keeps the rules it had
+	os.WriteFile(path, []byte("allow everyone\n"), 0644)
+	os.Chtimes(path, later.Add(time.Minute), later.Add(time.Minute))
+
+	assert.Equal(t, 200, ipFilterStatus(g, "/", "198.51.100.1:1234"))
+}
+
+func TestIPFilterInvalid(t *testing.T) {
+	assert.Panics(t, func() {
+		IPFilter("10.0.0.0/40")
+	})
+}
@@ -1,8 +1,13 @@
 package middleware
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/joho/godotenv"
@@ -41,49 +46,169 @@ func JWTError(c grinder.Context) error {
 	return c.JSON(500, "JWT Error")
 }
 
-// JWT default json web token handler
+var (
+	defaultJWT   grinder.Middleware
+	defaultJWTMu sync.Mutex
+)
+
+// JWT default json web token handler, the secret is read from JWT_SECRET in
+// .env the first time it is used
 func JWT(c grinder.Context, handler grinder.Handler) grinder.Handler {
+	m, err := loadDefaultJWT()
+	if err != nil {
+		log.Println(err)
+		return JWTError
+	}
+
+	return m(c, handler)
+}
+
+// loadDefaultJWT reads .env until it succeeds, a missing file or empty
+// JWT_SECRET fails the request instead of the process
+func loadDefaultJWT() (grinder.Middleware, error) {
+	defaultJWTMu.Lock()
+	defer defaultJWTMu.Unlock()
+
+	if defaultJWT != nil {
+		return defaultJWT, nil
+	}
+
 	config, err := godotenv.Read()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		return nil, fmt.Errorf("jwt: error loading .env file: %v", err)
+	}
+
+	// an empty HMAC key is accepted by the parser, anyone could sign tokens
+	if config["JWT_SECRET"] == "" {
+		return nil, errors.New("jwt: JWT_SECRET is empty")
 	}
 
 	j := DefaultJWT
 	j.SigningKey = []byte(config["JWT_SECRET"])
+	defaultJWT = JWTWithConfig(j)
+
+	return defaultJWT, nil
+}
+
+// JWTWithConfig returns a configured json web token middleware. Tokens must
+// be signed with SigningMethod and SigningKey, which must not be empty.
+func JWTWithConfig(j JWTConfig) grinder.Middleware {
+	switch key := j.SigningKey.(type) {
+	case nil:
+		panic("grinder: jwt requires a SigningKey")
+	case []byte:
+		if len(key) == 0 {
+			panic("grinder: jwt requires a SigningKey")
+		}
+	case string:
+		if key == "" {
+			panic("grinder: jwt requires a SigningKey")
+		}
+	}
+
+	if j.SigningMethod == "" {
+		j.SigningMethod = DefaultJWT.SigningMethod
+	}
 
 	parser := parseFromQuery()
 	switch j.ParseFrom {
+	case "header":
+		parser = parseFromHeader()
 	case "query":
 		parser = parseFromQuery()
 	}
 
-	parsed, err := parser(c)
-	if err != nil {
-		log.Println(err)
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != j.SigningMethod {
+			return nil, errors.New("JWT signing method is invalid")
+		}
+
+		return j.SigningKey, nil
 	}
 
-	token := new(jwt.Token)
-	if _, ok := j.Claims.(jwt.MapClaims); ok {
-		token, err = jwt.Parse(parsed, func(token *jwt.Token) (interface{}, error) {
-			return []byte("AllYourBase"), nil
-		})
+	return func(c grinder.Context, handler grinder.Handler) grinder.Handler {
+		parsed, err := parser(c)
+		if err != nil {
+			log.Println(err)
+			return JWTError
+		}
+
+		// claims are filled in by the parser, so every request gets its own
+		var claims jwt.Claims = jwt.MapClaims{}
+		if t := reflect.TypeOf(j.Claims); t != nil && t.Kind() == reflect.Ptr {
+			claims = reflect.New(t.Elem()).Interface().(jwt.Claims)
+		}
+
+		token, err := jwt.ParseWithClaims(parsed, claims, keyFunc)
+		if err == nil && token.Valid {
+			return func(c grinder.Context) error {
+				return handler(c.WithContext(context.WithValue(c.Request().Context(), jwtClaimsKey{}, token.Claims)))
+			}
+		}
+
+		return JWTError
+	}
+}
+
+type jwtClaimsKey struct{}
+
+// JWTClaims returns the claims of the token verified by JWT or
+// JWTWithConfig, or nil
+func JWTClaims(ctx context.Context) jwt.Claims {
+	claims, _ := ctx.Value(jwtClaimsKey{}).(jwt.Claims)
+	return claims
+}
+
+// jwtSubject returns the sub claim of MapClaims, StandardClaims or custom
+// claims embedding StandardClaims
+func jwtSubject(claims jwt.Claims) string {
+	if m, ok := claims.(jwt.MapClaims); ok {
+		sub, _ := m["sub"].(string)
+		return sub
 	}
 
-	if token.Valid && err == nil {
-		return handler
+	v := reflect.Indirect(reflect.ValueOf(claims))
+	if v.Kind() != reflect.Struct {
+		return ""
 	}
 
-	return JWTError
+	if f := v.FieldByName("Subject"); f.IsValid() && f.Kind() == reflect.String {
+		return f.String()
+	}
+
+	return ""
 }
 
 // expects http://domain.com?token=<HASH>
 func parseFromQuery() TokenParser {
+	return parseFromParam("token", errors.New("JWT token is missing"))
+}
+
+// expects Authorization: Bearer <HASH>
+func parseFromHeader() TokenParser {
+	return parseFromHeaderName("Authorization", "Bearer ", errors.New("JWT token is missing"))
+}
+
+// expects http://domain.com?<name>=<HASH>
+func parseFromParam(name string, missing error) TokenParser {
 	return func(c grinder.Context) (string, error) {
-		token := c.GetParam("token")
+		token := c.GetParam(name)
 		if token == "" {
-			return "", errors.New("JWT token is missing")
+			return "", missing
 		}
 
 		return token, nil
 	}
 }
+
+// expects <name>: <prefix><HASH>
+func parseFromHeaderName(name string, prefix string, missing error) TokenParser {
+	return func(c grinder.Context) (string, error) {
+		token := c.GetHeader(name)
+		if token == "" || !strings.HasPrefix(token, prefix) {
+			return "", missing
+		}
+
+		return strings.TrimPrefix(token, prefix), nil
+	}
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTMissingEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	g := grinder.New()
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, JWT)
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/?token=x", nil))
+
+	assert.Equal(t, 500, rec.Code)
+}
+
+func TestJWTEmptySecret(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("JWT_SECRET=\n"), 0600))
+	t.Chdir(dir)
+
+	g := grinder.New()
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, JWT)
+
+	// a token signed with the empty key must not get through
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "user"}).SignedString([]byte(""))
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/?token="+token, nil))
+	assert.Equal(t, 500, rec.Code)
+
+	assert.Panics(t, func() {
+		JWTWithConfig(JWTConfig{})
+	})
+	assert.Panics(t, func() {
+		JWTWithConfig(JWTConfig{SigningKey: []byte("")})
+	})
+}
+
+func TestJWTWithConfig(t *testing.T) {
+	key := []byte("secret")
+
+	g := grinder.New()
+	g.GET("/query", func(c grinder.Context) error {
+		return c.Code(200)
+	}, JWTWithConfig(JWTConfig{SigningKey: key}))
+
+	g.GET("/header", func(c grinder.Context) error {
+		return c.Code(200)
+	}, JWTWithConfig(JWTConfig{SigningKey: key, ParseFrom: "header", Claims: &jwt.StandardClaims{}}))
+
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "user"}).SignedString(key)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "user"}).SignedString([]byte("other"))
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/query?token="+token, nil))
+	assert.Equal(t, 200, rec.Code)
+
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/query?token="+forged, nil))
+	assert.Equal(t, 500, rec.Code)
+
+	req := httptest.NewRequest("GET", "/header", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 200, rec.Code)
+
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/header", nil))
+	assert.Equal(t, 500, rec.Code)
+}
+
+func TestJWTSigningMethod(t *testing.T) {
+	key := []byte("secret")
+
+	g := grinder.New()
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, JWTWithConfig(JWTConfig{SigningKey: key}))
+
+	hs512, _ := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.StandardClaims{Subject: "user"}).SignedString(key)
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.StandardClaims{Subject: "user"}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	for _, token := range []string{hs512, none} {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest("GET", "/?token="+token, nil))
+		assert.Equal(t, 500, rec.Code)
+	}
+}
+
+func TestJWTFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("JWT_SECRET=secret\n"), 0600))
+	t.Chdir(dir)
+
+	defer func() {
+		defaultJWTMu.Lock()
+		defaultJWT = nil
+		defaultJWTMu.Unlock()
+	}()
+
+	g := grinder.New()
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, JWT)
+
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "user"}).SignedString([]byte("secret"))
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/?token="+token, nil))
+	assert.Equal(t, 200, rec.Code)
+}
+
+func TestJWTFormToken(t *testing.T) {
+	key := []byte("secret")
+
+	g := grinder.New()
+	g.Before(JWTWithConfig(JWTConfig{SigningKey: key}))
+	g.POST("/", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "user"}).SignedString(key)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("token="+token))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+}
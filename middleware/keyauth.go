@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rinkbase/grinder"
+)
+
+// KeyAuthValidator checks an API key, returning the metadata kept for it.
+// Return ErrKeyInvalid for unknown keys and ErrKeyForbidden for keys which
+// are known but not allowed, i.e. revoked or missing a scope, either may be
+// wrapped.
+type KeyAuthValidator func(key string, c grinder.Context) (interface{}, error)
+
+// KeyAuthConfig configuration for KeyAuth middleware
+type KeyAuthConfig struct {
+	KeyLookup string // Defaults to header:X-API-Key (options: header:<name>, header:<name>:<prefix>, query:<name>, comma separated)
+	Validator KeyAuthValidator
+}
+
+// DefaultKeyAuthConfig handles the default KeyAuth configuration for grinder
+var DefaultKeyAuthConfig = KeyAuthConfig{
+	KeyLookup: "header:X-API-Key",
+}
+
+var (
+	// ErrKeyMissing is returned when no lookup location holds a key
+	ErrKeyMissing = errors.New("API key is missing")
+
+	// ErrKeyInvalid is returned by validators for unknown keys, results in a 401
+	ErrKeyInvalid = errors.New("API key is invalid")
+
+	// ErrKeyForbidden is returned by validators for keys which may not be used, results in a 403
+	ErrKeyForbidden = errors.New("API key is forbidden")
+)
+
+type keyAuthMetaKey struct{}
+
+// KeyAuth protects routes with an API key in the X-API-Key header checked by fn
+func KeyAuth(fn KeyAuthValidator) grinder.Middleware {
+	config := DefaultKeyAuthConfig
+	config.Validator = fn
+
+	return KeyAuthWithConfig(config)
+}
+
+// KeyAuthWithConfig returns a configured KeyAuth middleware. Missing and
+// invalid keys get a 401, forbidden keys a 403, the metadata of valid ones
+// is available through KeyAuthMeta.
+func KeyAuthWithConfig(config KeyAuthConfig) grinder.Middleware {
+	if config.Validator == nil {
+		panic("grinder: key auth middleware requires a validator")
+	}
+
+	if config.KeyLookup == "" {
+		config.KeyLookup = DefaultKeyAuthConfig.KeyLookup
+	}
+
+	parsers, err := keyParsers(config.KeyLookup)
+	if err != nil {
+		panic("grinder: " + err.Error())
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			key := ""
+			for _, parser := range parsers {
+				if k, err := parser(c); err == nil {
+					key = k
+					break
+				}
+			}
+
+			if key == "" {
+				return keyAuthError(http.StatusUnauthorized, ErrKeyMissing)
+			}
+
+			meta, err := config.Validator(key, c)
+			switch {
+			case err == nil:
+				return handler(c.WithContext(context.WithValue(c.Request().Context(), keyAuthMetaKey{}, meta)))
+			case errors.Is(err, ErrKeyInvalid):
+				return keyAuthError(http.StatusUnauthorized, err)
+			case errors.Is(err, ErrKeyForbidden):
+				return keyAuthError(http.StatusForbidden, err)
+			default:
+				return err
+			}
+		}
+	}
+}
+
+// KeyAuthMeta returns the metadata of the key authenticated by KeyAuth
+func KeyAuthMeta(ctx context.Context) interface{} {
+	return ctx.Value(keyAuthMetaKey{})
+}
+
+// HashKey returns the hex encoded SHA-256 hash of key, as kept by KeyStore
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyStore holds API keys by their hash so the keys themselves are never
+// stored, its Validate method is a KeyAuthValidator
+type KeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]storedKey
+}
+
+type storedKey struct {
+	meta    interface{}
+	revoked bool
+}
+
+// NewKeyStore creates an empty KeyStore
+func NewKeyStore() *KeyStore {
+	return &KeyStore{keys: make(map[string]storedKey)}
+}
+
+// Add stores key with its metadata
+func (s *KeyStore) Add(key string, meta interface{}) {
+	s.AddHash(HashKey(key), meta)
+}
+
+// AddHash stores a key by a hash from HashKey, i.e. loaded from a database
+func (s *KeyStore) AddHash(hash string, meta interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[strings.ToLower(hash)] = storedKey{meta: meta}
+}
+
+// Revoke keeps the key known but forbidden
+func (s *KeyStore) Revoke(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := HashKey(key)
+	if k, ok := s.keys[hash]; ok {
+		k.revoked = true
+		s.keys[hash] = k
+	}
+}
+
+// Validate implements KeyAuthValidator
+func (s *KeyStore) Validate(key string, c grinder.Context) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[HashKey(key)]
+	if !ok {
+		return nil, ErrKeyInvalid
+	}
+
+	if k.revoked {
+		return nil, ErrKeyForbidden
+	}
+
+	return k.meta, nil
+}
+
+func keyParsers(lookup string) ([]TokenParser, error) {
+	var parsers []TokenParser
+
+	for _, source := range strings.Split(lookup, ",") {
+		parts := strings.SplitN(strings.TrimLeft(source, " "), ":", 3)
+		if len(parts) < 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid key lookup %q", source)
+		}
+
+		switch parts[0] {
+		case "header":
+			prefix := ""
+			if len(parts) == 3 {
+				prefix = parts[2]
+			}
+			parsers = append(parsers, parseFromHeaderName(parts[1], prefix, ErrKeyMissing))
+		case "query":
+			parsers = append(parsers, parseFromParam(parts[1], ErrKeyMissing))
+		default:
+			return nil, fmt.Errorf("invalid key lookup %q", source)
+		}
+	}
+
+	return parsers, nil
+}
+
+func keyAuthError(code int, err error) error {
+	return &grinder.HTTPError{
+		Code:    code,
+		Message: http.StatusText(code),
+		Inner:   err,
+	}
+}
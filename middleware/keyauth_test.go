@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+type partner struct {
+	Name string
+}
+
+func TestKeyAuth(t *testing.T) {
+	store := NewKeyStore()
+	store.Add("valid-key", partner{"acme"})
+	store.Add("revoked-key", partner{"globex"})
+	store.Revoke("revoked-key")
+
+	m := KeyAuth(store.Validate)
+
+	var meta interface{}
+	rec := serve(m, func(c grinder.Context) error {
+		meta = KeyAuthMeta(c)
+		return c.Code(200)
+	}, "GET", "/", map[string]string{"X-API-Key": "valid-key"})
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, partner{"acme"}, meta)
+
+	rec = serve(m, okHandler, "GET", "/", nil)
+	assert.Equal(t, 401, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", map[string]string{"X-API-Key": "unknown-key"})
+	assert.Equal(t, 401, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", map[string]string{"X-API-Key": "revoked-key"})
+	assert.Equal(t, 403, rec.Code)
+}
+
+func TestKeyAuthWrappedErrors(t *testing.T) {
+	m := KeyAuth(func(key string, c grinder.Context) (interface{}, error) {
+		if key == "revoked-key" {
+			return nil, fmt.Errorf("partner globex: %w", ErrKeyForbidden)
+		}
+		return nil, fmt.Errorf("lookup %s: %w", key, ErrKeyInvalid)
+	})
+
+	rec := serve(m, okHandler, "GET", "/", map[string]string{"X-API-Key": "unknown-key"})
+	assert.Equal(t, 401, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", map[string]string{"X-API-Key": "revoked-key"})
+	assert.Equal(t, 403, rec.Code)
+}
+
+func TestKeyAuthLookup(t *testing.T) {
+	store := NewKeyStore()
+	store.AddHash(HashKey("valid-key"), partner{"acme"})
+
+	m := KeyAuthWithConfig(KeyAuthConfig{
+		KeyLookup: "query:api_key, header:Authorization:ApiKey ",
+		Validator: store.Validate,
+	})
+
+	rec := serve(m, okHandler, "GET", "/?api_key=valid-key", nil)
+	assert.Equal(t, 200, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", map[string]string{"Authorization": "ApiKey valid-key"})
+	assert.Equal(t, 200, rec.Code)
+
+	rec = serve(m, okHandler, "GET", "/", map[string]string{"Authorization": "Bearer valid-key"})
+	assert.Equal(t, 401, rec.Code)
+}
+
+func TestKeyAuthInvalidLookup(t *testing.T) {
+	assert.Panics(t, func() {
+		KeyAuthWithConfig(KeyAuthConfig{KeyLookup: "cookie:key", Validator: NewKeyStore().Validate})
+	})
+}
+
+func TestHashKey(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", HashKey(""))
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/rinkbase/grinder"
+)
+
+// LoggerConfig configuration for Logger middleware
+type LoggerConfig struct {
+	Output     io.Writer    // Defaults to os.Stdout
+	Format     string       // Defaults to json (options: json, logfmt)
+	Handler    slog.Handler // When set, records go to the handler and Output/Format are ignored
+	SampleRate float64      // Fraction of requests logged, defaults to 1. Server errors are always logged
+	Skip       []string     // Request paths which are never logged, i.e. health checks
+	Service    string       // Added to every record when set, i.e. from config.Service
+	Version    string
+
+	// RequestIDHeader is read from the response when Logger runs outside
+	// RequestID, set it to the header RequestID was configured with
+	RequestIDHeader string // Defaults to X-Request-ID
+}
+
+const (
+	// LogFormatJSON writes one JSON object per request
+	LogFormatJSON = "json"
+
+	// LogFormatLogfmt writes one line of key=value pairs per request
+	LogFormatLogfmt = "logfmt"
+)
+
+// DefaultLoggerConfig handles the default Logger configuration for grinder
+var DefaultLoggerConfig = LoggerConfig{
+	Format:     LogFormatJSON,
+	SampleRate: 1,
+}
+
+// Logger middleware writes an access log record for every request to stdout
+func Logger(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+	return defaultLogger(ctx, handler)
+}
+
+var defaultLogger = LoggerWithConfig(DefaultLoggerConfig)
+
+// LoggerWithConfig returns a configured Logger middleware, register it with
+// Grinder.Around to log requests which match no route too
+func LoggerWithConfig(config LoggerConfig) grinder.Middleware {
+	if config.Output == nil {
+		config.Output = os.Stdout
+	}
+
+	if config.RequestIDHeader == "" {
+		config.RequestIDHeader = DefaultRequestIDConfig.Header
+	}
+
+	if config.Format == "" {
+		config.Format = DefaultLoggerConfig.Format
+	}
+
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		config.SampleRate = DefaultLoggerConfig.SampleRate
+	}
+
+	h := config.Handler
+	if h == nil {
+		switch config.Format {
+		case LogFormatLogfmt:
+			h = slog.NewTextHandler(config.Output, nil)
+		default:
+			h = slog.NewJSONHandler(config.Output, nil)
+		}
+	}
+
+	logger := slog.New(h)
+	if config.Service != "" {
+		logger = logger.With(slog.String("service", config.Service))
+	}
+
+	if config.Version != "" {
+		logger = logger.With(slog.String("version", config.Version))
+	}
+
+	skip := make(map[string]bool)
+	for _, p := range config.Skip {
+		skip[p] = true
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			req := c.Request()
+			if skip[req.URL.Path] {
+				return handler(c)
+			}
+
+			start := time.Now()
+
+			err := handler(c)
+			status := grinder.CommitError(c, err)
+			latency := time.Since(start)
+
+			res := c.Response()
+
+			if status < 500 && config.SampleRate < 1 && rand.Float64() >= config.SampleRate {
+				return err
+			}
+
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			} else if status >= 400 {
+				level = slog.LevelWarn
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+				slog.String("route", c.Path()),
+				slog.Int("status", status),
+				slog.Int64("bytes", res.Size),
+				slog.Duration("latency", latency),
+				slog.String("remote_ip", c.RealIP()),
+				slog.String("request_id", requestID(c, config.RequestIDHeader)),
+				slog.String("user_agent", req.UserAgent()),
+			}
+
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+
+			logger.LogAttrs(c, level, "request", attrs...)
+
+			return err
+		}
+	}
+}
+
+// requestID prefers the id stored by RequestID, falling back to the response
+// header for when Logger runs outside of it
+func requestID(c grinder.Context, header string) string {
+	if id := RequestIDFromContext(c); id != "" {
+		return id
+	}
+
+	return c.Response().Header().Get(header)
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	g := grinder.New()
+	g.Around(LoggerWithConfig(LoggerConfig{Output: buf}))
+
+	g.GET("/users/:id", func(c grinder.Context) error {
+		return c.JSON(200, "This is a test")
+	})
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("User-Agent", "grinder-test")
+	req.RemoteAddr = "10.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	record := make(map[string]interface{})
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &record)) {
+		assert.Equal(t, "GET", record["method"])
+		assert.Equal(t, "/users/1", record["path"])
+		assert.Equal(t, "/users/:id", record["route"])
+		assert.Equal(t, float64(200), record["status"])
+		assert.Equal(t, float64(len("\"This is a test\"")), record["bytes"])
+		assert.Equal(t, "10.0.0.1", record["remote_ip"])
+		assert.Equal(t, "grinder-test", record["user_agent"])
+	}
+}
+
+func TestLoggerLogfmtRecordsErrors(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	g := grinder.New()
+	g.Around(LoggerWithConfig(LoggerConfig{Output: buf, Format: LogFormatLogfmt, Service: "orders", Version: "1.2.0"}))
+
+	g.GET("/", func(c grinder.Context) error {
+		return errors.New("something went wrong")
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 500, rec.Code)
+	assert.True(t, strings.Contains(buf.String(), "status=500"))
+	assert.True(t, strings.Contains(buf.String(), "level=ERROR"))
+	assert.True(t, strings.Contains(buf.String(), `error="something went wrong"`))
+	assert.True(t, strings.Contains(buf.String(), "service=orders version=1.2.0"))
+}
+
+func TestLoggerNotFound(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	g := grinder.New()
+	g.Around(LoggerWithConfig(LoggerConfig{Output: buf, Format: LogFormatLogfmt}))
+
+	req := httptest.NewRequest("GET", "/missing", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.True(t, strings.Contains(buf.String(), "status=404"))
+}
+
+func TestLoggerSkip(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	g := grinder.New()
+	g.Around(LoggerWithConfig(LoggerConfig{Output: buf, Skip: []string{"/healthz"}}))
+
+	g.GET("/healthz", func(c grinder.Context) error {
+		return c.Code(204)
+	})
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 204, rec.Code)
+	assert.Empty(t, buf.String())
+}
+
+func TestLoggerSampling(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	g := grinder.New()
+	g.Around(LoggerWithConfig(LoggerConfig{Output: buf, SampleRate: 0.0000001}))
+
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	g.GET("/error", func(c grinder.Context) error {
+		return c.Code(503)
+	})
+
+	for i := 0; i < 10; i++ {
+		g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	assert.Empty(t, buf.String())
+
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/error", nil))
+
+	assert.NotEmpty(t, buf.String())
+}
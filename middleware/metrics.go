@@ -0,0 +1,325 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rinkbase/grinder"
+)
+
+// MetricsConfig configuration for Metrics middleware
+type MetricsConfig struct {
+	Registry *MetricsRegistry // Defaults to DefaultMetricsRegistry
+	Skip     []string         // Request paths which are not measured, i.e. /metrics
+}
+
+// DefaultMetricsBuckets are the latency histogram buckets in seconds
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultMetricsRegistry collects the metrics of Metrics and is served by
+// MetricsHandler
+var DefaultMetricsRegistry = NewMetricsRegistry("", nil)
+
+// DefaultMetricsConfig handles the default Metrics configuration for grinder
+var DefaultMetricsConfig = MetricsConfig{
+	Registry: DefaultMetricsRegistry,
+}
+
+// unmatchedRoute labels requests which matched no route, so unknown paths
+// can't grow the number of series
+const unmatchedRoute = "unmatched"
+
+// otherMethod labels non-standard request methods, which clients choose
+const otherMethod = "other"
+
+var metricMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+func metricMethod(method string) string {
+	if metricMethods[method] {
+		return method
+	}
+
+	return otherMethod
+}
+
+// Metrics middleware records request counts, latencies and in-flight
+// requests in DefaultMetricsRegistry
+func Metrics(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+	return defaultMetrics(ctx, handler)
+}
+
+var defaultMetrics = MetricsWithConfig(DefaultMetricsConfig)
+
+// MetricsWithConfig returns a configured Metrics middleware. Requests are
+// labelled by method, route pattern and status, register it with
+// Grinder.Around to count requests which match no route too.
+func MetricsWithConfig(config MetricsConfig) grinder.Middleware {
+	if config.Registry == nil {
+		config.Registry = DefaultMetricsConfig.Registry
+	}
+
+	skip := make(map[string]bool)
+	for _, p := range config.Skip {
+		skip[p] = true
+	}
+
+	r := config.Registry
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			req := c.Request()
+			if skip[req.URL.Path] {
+				return handler(c)
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = unmatchedRoute
+			}
+
+			method := metricMethod(req.Method)
+
+			r.inFlight(method, route, 1)
+			defer r.inFlight(method, route, -1)
+
+			start := time.Now()
+
+			err := handler(c)
+			status := grinder.CommitError(c, err)
+
+			r.observe(method, route, strconv.Itoa(status), time.Since(start))
+
+			return err
+		}
+	}
+}
+
+// MetricsHandler serves DefaultMetricsRegistry in the Prometheus text format
+func MetricsHandler(c grinder.Context) error {
+	return DefaultMetricsRegistry.Handler(c)
+}
+
+// MetricsRegistry holds the series recorded by Metrics
+type MetricsRegistry struct {
+	namespace string
+	buckets   []float64
+
+	mu        sync.Mutex
+	requests  map[metricLabels]*requestSeries
+	inFlights map[metricLabels]int64
+}
+
+type metricLabels struct {
+	method string
+	route  string
+	status string
+}
+
+type requestSeries struct {
+	count   uint64
+	sum     float64
+	buckets []uint64 // observations per bucket, the last one is +Inf
+}
+
+// NewMetricsRegistry creates an empty registry. Metric names are prefixed
+// with namespace when set, buckets default to DefaultMetricsBuckets.
+func NewMetricsRegistry(namespace string, buckets []float64) *MetricsRegistry {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	if namespace != "" {
+		namespace += "_"
+	}
+
+	return &MetricsRegistry{
+		namespace: namespace,
+		buckets:   buckets,
+		requests:  make(map[metricLabels]*requestSeries),
+		inFlights: make(map[metricLabels]int64),
+	}
+}
+
+func (r *MetricsRegistry) inFlight(method string, route string, delta int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := metricLabels{method: method, route: route}
+	r.inFlights[key] += delta
+
+	// idle series are dropped, so the map only holds requests being served
+	if r.inFlights[key] == 0 {
+		delete(r.inFlights, key)
+	}
+}
+
+func (r *MetricsRegistry) observe(method string, route string, status string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := metricLabels{method: method, route: route, status: status}
+	s, ok := r.requests[key]
+	if !ok {
+		s = &requestSeries{buckets: make([]uint64, len(r.buckets)+1)}
+		r.requests[key] = s
+	}
+
+	seconds := d.Seconds()
+	s.count++
+	s.sum += seconds
+	s.buckets[sort.SearchFloat64s(r.buckets, seconds)]++
+}
+
+// Handler serves the registry in the Prometheus text format, i.e.
+// svc.GET("/metrics", registry.Handler)
+func (r *MetricsRegistry) Handler(c grinder.Context) error {
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	c.SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+	_, err := c.Response().Write(buf.Bytes())
+
+	return err
+}
+
+// WriteTo writes every series in the Prometheus text exposition format
+func (r *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	ns := r.namespace
+
+	keys := make([]metricLabels, 0, len(r.requests))
+	for k := range r.requests {
+		keys = append(keys, k)
+	}
+	sortLabels(keys)
+
+	fmt.Fprintf(cw, "# HELP %shttp_requests_total Total number of HTTP requests.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %shttp_requests_total counter\n", ns)
+	for _, k := range keys {
+		fmt.Fprintf(cw, "%shttp_requests_total{%s} %d\n", ns, k.format(), r.requests[k].count)
+	}
+
+	fmt.Fprintf(cw, "# HELP %shttp_request_duration_seconds HTTP request latencies in seconds.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %shttp_request_duration_seconds histogram\n", ns)
+	for _, k := range keys {
+		s := r.requests[k]
+		labels := k.format()
+
+		var cumulative uint64
+		for i, le := range r.buckets {
+			cumulative += s.buckets[i]
+			fmt.Fprintf(cw, "%shttp_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", ns, labels, formatFloat(le), cumulative)
+		}
+
+		fmt.Fprintf(cw, "%shttp_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", ns, labels, s.count)
+		fmt.Fprintf(cw, "%shttp_request_duration_seconds_sum{%s} %s\n", ns, labels, formatFloat(s.sum))
+		fmt.Fprintf(cw, "%shttp_request_duration_seconds_count{%s} %d\n", ns, labels, s.count)
+	}
+
+	flights := make([]metricLabels, 0, len(r.inFlights))
+	for k := range r.inFlights {
+		flights = append(flights, k)
+	}
+	sortLabels(flights)
+
+	fmt.Fprintf(cw, "# HELP %shttp_requests_in_flight HTTP requests currently being served.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %shttp_requests_in_flight gauge\n", ns)
+	for _, k := range flights {
+		fmt.Fprintf(cw, "%shttp_requests_in_flight{%s} %d\n", ns, k.format(), r.inFlights[k])
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+// format renders the labels which are set, in a fixed order
+func (l metricLabels) format() string {
+	parts := []string{
+		`method="` + escapeLabel(l.method) + `"`,
+		`route="` + escapeLabel(l.route) + `"`,
+	}
+
+	if l.status != "" {
+		parts = append(parts, `status="`+escapeLabel(l.status)+`"`)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func sortLabels(keys []metricLabels) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+
+		return keys[i].status < keys[j].status
+	})
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countingWriter tracks the bytes written and the first error for WriteTo
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	cw.err = err
+
+	return n, err
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := NewMetricsRegistry("orders", []float64{0.1, 1})
+
+	g := grinder.New()
+	g.Around(MetricsWithConfig(MetricsConfig{Registry: registry, Skip: []string{"/metrics"}}))
+
+	g.GET("/users/:id", func(c grinder.Context) error {
+		return c.JSON(200, "ok")
+	})
+	g.GET("/fail", func(c grinder.Context) error {
+		return errors.New("boom")
+	})
+	g.GET("/metrics", registry.Handler)
+
+	for _, path := range []string{"/users/1", "/users/2", "/fail", "/missing/1", "/missing/2"} {
+		g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	assert.Equal(t, 200, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+
+	// labelled by the route pattern, unknown paths share one series
+	assert.Contains(t, body, "# TYPE orders_http_requests_total counter\n")
+	assert.Contains(t, body, `orders_http_requests_total{method="GET",route="/users/:id",status="200"} 2`)
+	assert.Contains(t, body, `orders_http_requests_total{method="GET",route="/fail",status="500"} 1`)
+	assert.Contains(t, body, `orders_http_requests_total{method="GET",route="unmatched",status="404"} 2`)
+	assert.NotContains(t, body, "/users/1")
+	assert.NotContains(t, body, `route="/metrics"`)
+
+	assert.Contains(t, body, "# TYPE orders_http_request_duration_seconds histogram\n")
+	assert.Contains(t, body, `orders_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="0.1"} 2`)
+	assert.Contains(t, body, `orders_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="+Inf"} 2`)
+	assert.Contains(t, body, `orders_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`)
+
+	assert.Contains(t, body, "# TYPE orders_http_requests_in_flight gauge\n")
+	assert.NotContains(t, body, `orders_http_requests_in_flight{method="GET",route="/users/:id"}`)
+}
+
+func TestMetricsMethods(t *testing.T) {
+	registry := NewMetricsRegistry("", nil)
+
+	g := grinder.New()
+	g.Around(MetricsWithConfig(MetricsConfig{Registry: registry}))
+
+	for i := 0; i < 50; i++ {
+		g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("MADEUP"+strconv.Itoa(i), "/", nil))
+	}
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PATCH", "/", nil))
+
+	var b strings.Builder
+	registry.WriteTo(&b)
+
+	// clients can't add series by inventing methods
+	assert.Contains(t, b.String(), `http_requests_total{method="other",route="unmatched",status="404"} 50`)
+	assert.Contains(t, b.String(), `http_requests_total{method="PATCH",route="unmatched",status="404"} 1`)
+	assert.NotContains(t, b.String(), "MADEUP")
+}
+
+func TestMetricsInFlight(t *testing.T) {
+	registry := NewMetricsRegistry("", nil)
+
+	g := grinder.New()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c := g.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+		c.SetPath("/slow")
+		MetricsWithConfig(MetricsConfig{Registry: registry})(c, func(c grinder.Context) error {
+			close(started)
+			<-release
+			return c.Code(200)
+		})(c)
+	}()
+	<-started
+
+	var b strings.Builder
+	registry.WriteTo(&b)
+	assert.Contains(t, b.String(), `http_requests_in_flight{method="GET",route="/slow"} 1`)
+
+	close(release)
+	<-done
+
+	b.Reset()
+	registry.WriteTo(&b)
+	assert.NotContains(t, b.String(), `http_requests_in_flight{method="GET",route="/slow"}`)
+	assert.Contains(t, b.String(), `http_request_duration_seconds_bucket{method="GET",route="/slow",status="200",le="0.005"}`)
+}
+
+func TestMetricsHistogramBuckets(t *testing.T) {
+	registry := NewMetricsRegistry("", []float64{1, 0.1})
+	registry.observe("GET", "/", "200", 50*time.Millisecond)
+	registry.observe("GET", "/", "200", 500*time.Millisecond)
+	registry.observe("GET", "/", "200", 5*time.Second)
+
+	var b strings.Builder
+	registry.WriteTo(&b)
+
+	assert.Contains(t, b.String(), `http_request_duration_seconds_bucket{method="GET",route="/",status="200",le="0.1"} 1`)
+	assert.Contains(t, b.String(), `http_request_duration_seconds_bucket{method="GET",route="/",status="200",le="1"} 2`)
+	assert.Contains(t, b.String(), `http_request_duration_seconds_bucket{method="GET",route="/",status="200",le="+Inf"} 3`)
+	assert.Contains(t, b.String(), `http_request_duration_seconds_sum{method="GET",route="/",status="200"} 5.55`)
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http/httptest"
+
+	"github.com/rinkbase/grinder"
+)
+
+// okHandler answers 200 with no body
+func okHandler(c grinder.Context) error {
+	return c.Code(200)
+}
+
+// serve sends one request through m to h, which is registered for GET and
+// OPTIONS on / of a fresh grinder
+func serve(m grinder.Middleware, h grinder.Handler, method string, target string, headers map[string]string) *httptest.ResponseRecorder {
+	g := grinder.New()
+	g.GET("/", h, m)
+	g.OPTIONS("/", h, m)
+
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	return rec
+}
@@ -0,0 +1,388 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OTLPConfig configuration for OTLPExporter
+type OTLPConfig struct {
+	Endpoint    string            // OTLP/HTTP traces endpoint, defaults to a local collector
+	Headers     map[string]string // Sent with every export, i.e. an API key
+	ServiceName string            // Reported as the service.name resource attribute
+
+	BatchSize     int           // Spans per export request, defaults to 512
+	FlushInterval time.Duration // Longest a span waits to be exported, defaults to 5 seconds
+	QueueSize     int           // Spans waiting to be exported, further spans are dropped. Defaults to 2048
+	Timeout       time.Duration // Per export request, defaults to 10 seconds
+
+	Client *http.Client // Defaults to http.DefaultClient
+}
+
+// DefaultOTLPConfig handles the default OTLPExporter configuration for grinder
+var DefaultOTLPConfig = OTLPConfig{
+	Endpoint:      "http://localhost:4318/v1/traces",
+	BatchSize:     512,
+	FlushInterval: 5 * time.Second,
+	QueueSize:     2048,
+	Timeout:       10 * time.Second,
+}
+
+// ErrOTLPShutdown is returned by exports after Shutdown
+var ErrOTLPShutdown = errors.New("otlp: exporter is shut down")
+
+// OTLPExporter batches spans and sends them to an OpenTelemetry collector
+// as OTLP/HTTP JSON. Spans are exported in the background, so requests never
+// wait on the collector.
+type OTLPExporter struct {
+	config OTLPConfig
+
+	queue   chan *Span
+	flush   chan chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+
+	mu       sync.Mutex
+	shutdown bool
+	dropped  int
+}
+
+// NewOTLPExporter creates an exporter and starts its batching goroutine.
+// Register Shutdown to export the last spans, i.e. g.OnShutdown(exporter.Shutdown)
+func NewOTLPExporter(config OTLPConfig) *OTLPExporter {
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultOTLPConfig.Endpoint
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultOTLPConfig.BatchSize
+	}
+
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultOTLPConfig.FlushInterval
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultOTLPConfig.QueueSize
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultOTLPConfig.Timeout
+	}
+
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	e := &OTLPExporter{
+		config:  config,
+		queue:   make(chan *Span, config.QueueSize),
+		flush:   make(chan chan struct{}),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.run()
+
+	return e
+}
+
+// ExportSpans implements SpanExporter, queueing the spans for the next batch
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.shutdown {
+		return ErrOTLPShutdown
+	}
+
+	for _, span := range spans {
+		select {
+		case e.queue <- span:
+		default:
+			e.dropped++
+		}
+	}
+
+	return nil
+}
+
+// Flush exports the queued spans and waits until they are sent or ctx is done
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+
+	select {
+	case e.flush <- done:
+	case <-e.stopped:
+		return ErrOTLPShutdown
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the queued spans and stops the exporter, later spans are
+// rejected
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if e.shutdown {
+		e.mu.Unlock()
+		return nil
+	}
+	e.shutdown = true
+	close(e.stop)
+	e.mu.Unlock()
+
+	select {
+	case <-e.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects spans into batches, sending one when it is full or the
+// flush interval passes
+func (e *OTLPExporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, e.config.BatchSize)
+	send := func() {
+		if len(batch) > 0 {
+			if err := e.send(batch); err != nil {
+				log.Println(err)
+			}
+			batch = batch[:0]
+		}
+
+		e.mu.Lock()
+		dropped := e.dropped
+		e.dropped = 0
+		e.mu.Unlock()
+
+		if dropped > 0 {
+			log.Printf("otlp: queue full, dropped %d spans", dropped)
+		}
+	}
+
+	// drain sends everything queued so far
+	drain := func() {
+		for {
+			select {
+			case span := <-e.queue:
+				batch = append(batch, span)
+				if len(batch) >= e.config.BatchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.config.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-e.flush:
+			drain()
+			close(done)
+		case <-e.stop:
+			drain()
+			return
+		}
+	}
+}
+
+// send posts one batch to the collector
+func (e *OTLPExporter) send(spans []*Span) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := e.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp: export %d spans: %v", len(spans), err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("otlp: export %d spans: collector responded %s", len(spans), res.Status)
+	}
+
+	return nil
+}
+
+// OTLP JSON encoding of ExportTraceServiceRequest, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 is a string in OTLP JSON
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpScopeName  = "github.com/rinkbase/grinder"
+	otlpKindServer = 2
+	otlpStatusErr  = 2
+)
+
+func (e *OTLPExporter) payload(spans []*Span) otlpRequest {
+	var resource []otlpKeyValue
+	if e.config.ServiceName != "" {
+		resource = append(resource, otlpAttribute("service.name", e.config.ServiceName))
+	}
+
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			TraceState:        s.TraceState,
+			Name:              s.Name,
+			Kind:              otlpKindServer,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+
+		if s.ParentID.IsValid() {
+			span.ParentSpanID = s.ParentID.String()
+		}
+
+		keys := make([]string, 0, len(s.Attributes))
+		for k := range s.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			span.Attributes = append(span.Attributes, otlpAttribute(k, s.Attributes[k]))
+		}
+
+		// server spans are errors for 5xx only, 4xx is the client's fault
+		if s.Status >= http.StatusInternalServerError {
+			span.Status = otlpStatus{Code: otlpStatusErr, Message: s.Error}
+		}
+
+		out = append(out, span)
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: resource},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: otlpScopeName},
+				Spans: out,
+			}},
+		}},
+	}
+}
+
+func otlpAttribute(key string, v interface{}) otlpKeyValue {
+	var value otlpValue
+
+	switch t := v.(type) {
+	case string:
+		value.StringValue = &t
+	case int:
+		s := strconv.Itoa(t)
+		value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(t, 10)
+		value.IntValue = &s
+	case float64:
+		value.DoubleValue = &t
+	case bool:
+		value.BoolValue = &t
+	default:
+		s := fmt.Sprint(t)
+		value.StringValue = &s
+	}
+
+	return otlpKeyValue{Key: key, Value: value}
+}
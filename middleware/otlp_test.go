@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+// collector is a stand-in for an OpenTelemetry collector
+type collector struct {
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	headers  []http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	c.mu.Lock()
+	c.requests = append(c.requests, body)
+	c.headers = append(c.headers, r.Header)
+	c.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *collector) spans() []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var spans []interface{}
+	for _, req := range c.requests {
+		rs := req["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		ss := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})
+		spans = append(spans, ss["spans"].([]interface{})...)
+	}
+
+	return spans
+}
+
+func TestOTLPExporter(t *testing.T) {
+	col := &collector{}
+	server := httptest.NewServer(col)
+	defer server.Close()
+
+	exporter := NewOTLPExporter(OTLPConfig{
+		Endpoint:    server.URL + "/v1/traces",
+		Headers:     map[string]string{"X-API-Key": "secret"},
+		ServiceName: "orders",
+	})
+
+	g := grinder.New()
+	g.Around(TracingWithConfig(TracingConfig{Exporter: exporter}))
+	g.GET("/users/:id", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+	g.GET("/fail", func(c grinder.Context) error {
+		return grinder.NewHTTPError(503)
+	})
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+
+	assert.Nil(t, exporter.Flush(context.Background()))
+
+	col.mu.Lock()
+	if assert.Len(t, col.requests, 1) {
+		assert.Equal(t, "secret", col.headers[0].Get("X-API-Key"))
+		assert.Equal(t, "application/json", col.headers[0].Get("Content-Type"))
+
+		resource := col.requests[0]["resourceSpans"].([]interface{})[0].(map[string]interface{})["resource"]
+		assert.Equal(t, map[string]interface{}{
+			"attributes": []interface{}{
+				map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "orders"}},
+			},
+		}, resource)
+	}
+	col.mu.Unlock()
+
+	spans := col.spans()
+	if assert.Len(t, spans, 2) {
+		ok := spans[0].(map[string]interface{})
+		assert.Equal(t, "GET /users/:id", ok["name"])
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", ok["traceId"])
+		assert.Equal(t, "00f067aa0ba902b7", ok["parentSpanId"])
+		assert.Len(t, ok["spanId"], 16)
+		assert.Equal(t, float64(2), ok["kind"])
+		assert.Equal(t, map[string]interface{}{}, ok["status"])
+		assert.Contains(t, ok["attributes"], map[string]interface{}{
+			"key": "http.response.status_code", "value": map[string]interface{}{"intValue": "200"},
+		})
+
+		fail := spans[1].(map[string]interface{})
+		assert.Nil(t, fail["parentSpanId"])
+		assert.Equal(t, float64(2), fail["status"].(map[string]interface{})["code"])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, exporter.Shutdown(ctx))
+	assert.Equal(t, ErrOTLPShutdown, exporter.ExportSpans(ctx, []*Span{{}}))
+}
+
+func TestOTLPExporterBatches(t *testing.T) {
+	col := &collector{}
+	server := httptest.NewServer(col)
+	defer server.Close()
+
+	exporter := NewOTLPExporter(OTLPConfig{Endpoint: server.URL, BatchSize: 2, FlushInterval: time.Hour})
+
+	for i := 0; i < 5; i++ {
+		exporter.ExportSpans(context.Background(), []*Span{{TraceID: newTraceID(), SpanID: newSpanID()}})
+	}
+
+	// the last span is sent by Shutdown
+	assert.Nil(t, exporter.Shutdown(context.Background()))
+	assert.Len(t, col.spans(), 5)
+
+	col.mu.Lock()
+	assert.Len(t, col.requests, 3)
+	col.mu.Unlock()
+}
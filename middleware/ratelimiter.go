@@ -0,0 +1,333 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rinkbase/grinder"
+)
+
+// RateLimiterConfig configuration for RateLimiter middleware
+type RateLimiterConfig struct {
+	Store   RateLimiterStore
+	KeyFunc KeyFunc // Defaults to KeyByIP
+}
+
+// RateLimiterStore keeps the rate limiting state for every key, implement it
+// to share limits between instances (i.e. redis)
+type RateLimiterStore interface {
+	Allow(key string) (RateLimit, error)
+}
+
+// RateLimit is the outcome of a RateLimiterStore.Allow call
+type RateLimit struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the limit is fully restored
+	RetryAfter time.Duration // until the next request is allowed, when not Allowed
+}
+
+// KeyFunc extracts the key a request is limited by
+type KeyFunc func(grinder.Context) (string, error)
+
+const (
+	// AlgoTokenBucket refills Limit tokens evenly over Window, allowing bursts up to Limit
+	AlgoTokenBucket = "token_bucket"
+
+	// AlgoSlidingWindow allows Limit requests in any Window
+	AlgoSlidingWindow = "sliding_window"
+)
+
+// MemoryStoreConfig configuration for the in-memory RateLimiterStore
+type MemoryStoreConfig struct {
+	Algorithm string        // Defaults to token_bucket (options: token_bucket, sliding_window)
+	Limit     int           // Requests allowed per Window, defaults to 100
+	Window    time.Duration // Defaults to 1 minute
+	ExpiresIn time.Duration // Idle keys are evicted after, defaults to 3 Windows
+}
+
+// DefaultMemoryStoreConfig handles the default in-memory store configuration for grinder
+var DefaultMemoryStoreConfig = MemoryStoreConfig{
+	Algorithm: AlgoTokenBucket,
+	Limit:     100,
+	Window:    time.Minute,
+}
+
+// ErrRateLimitKey is returned by key funcs when the request carries no key
+var ErrRateLimitKey = errors.New("rate limit key is missing")
+
+// RateLimiter limits requests per client IP using store
+func RateLimiter(store RateLimiterStore) grinder.Middleware {
+	return RateLimiterWithConfig(RateLimiterConfig{Store: store})
+}
+
+// RateLimiterWithConfig returns a configured RateLimiter middleware. Limited
+// requests get a 429 with Retry-After, every response carries the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers. Requests
+// the key func finds no key in get a 401.
+func RateLimiterWithConfig(config RateLimiterConfig) grinder.Middleware {
+	if config.Store == nil {
+		config.Store = NewMemoryStore(DefaultMemoryStoreConfig)
+	}
+
+	if config.KeyFunc == nil {
+		config.KeyFunc = KeyByIP
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			key, err := config.KeyFunc(c)
+			if err != nil {
+				return &grinder.HTTPError{
+					Code:    http.StatusUnauthorized,
+					Message: http.StatusText(http.StatusUnauthorized),
+					Inner:   err,
+				}
+			}
+
+			limit, err := config.Store.Allow(key)
+			if err != nil {
+				return err
+			}
+
+			c.SetHeader("RateLimit-Limit", strconv.Itoa(limit.Limit))
+			c.SetHeader("RateLimit-Remaining", strconv.Itoa(limit.Remaining))
+			c.SetHeader("RateLimit-Reset", seconds(limit.Reset))
+
+			if !limit.Allowed {
+				c.SetHeader("Retry-After", seconds(limit.RetryAfter))
+				return grinder.NewHTTPError(http.StatusTooManyRequests)
+			}
+
+			return handler(c)
+		}
+	}
+}
+
+// KeyByIP limits by the client IP, see Grinder.TrustProxies
+func KeyByIP(c grinder.Context) (string, error) {
+	return c.RealIP(), nil
+}
+
+// KeyByHeader limits by the value of a request header, i.e. an API key
+func KeyByHeader(header string) KeyFunc {
+	return func(c grinder.Context) (string, error) {
+		key := c.GetHeader(header)
+		if key == "" {
+			return "", ErrRateLimitKey
+		}
+
+		return key, nil
+	}
+}
+
+// KeyByJWTSubject limits by the sub claim of the token verified by JWT or
+// JWTWithConfig, which must run first, i.e. with svc.Before
+func KeyByJWTSubject(c grinder.Context) (string, error) {
+	claims := JWTClaims(c)
+	if claims == nil {
+		return "", ErrRateLimitKey
+	}
+
+	sub := jwtSubject(claims)
+	if sub == "" {
+		return "", ErrRateLimitKey
+	}
+
+	return sub, nil
+}
+
+// MemoryStore is an in-memory RateLimiterStore, state is local to the process
+type MemoryStore struct {
+	mu        sync.Mutex
+	config    MemoryStoreConfig
+	entries   map[string]*rateEntry
+	lastSweep time.Time
+	now       func() time.Time
+
+	// expiresDefault follows ExpiresIn when the window changes
+	expiresDefault bool
+}
+
+type rateEntry struct {
+	// token bucket
+	tokens float64
+
+	// sliding window
+	windowStart time.Time
+	current     int
+	previous    int
+
+	last time.Time
+}
+
+// NewMemoryStore creates an in-memory RateLimiterStore
+func NewMemoryStore(config MemoryStoreConfig) *MemoryStore {
+	if config.Algorithm == "" {
+		config.Algorithm = DefaultMemoryStoreConfig.Algorithm
+	}
+
+	if config.Limit <= 0 {
+		config.Limit = DefaultMemoryStoreConfig.Limit
+	}
+
+	if config.Window <= 0 {
+		config.Window = DefaultMemoryStoreConfig.Window
+	}
+
+	expiresDefault := config.ExpiresIn <= 0
+	if expiresDefault {
+		config.ExpiresIn = 3 * config.Window
+	}
+
+	return &MemoryStore{
+		config:         config,
+		entries:        make(map[string]*rateEntry),
+		now:            time.Now,
+		expiresDefault: expiresDefault,
+	}
+}
+
+// Allow implements RateLimiterStore
+func (s *MemoryStore) Allow(key string) (RateLimit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &rateEntry{tokens: float64(s.config.Limit), windowStart: now, last: now}
+		s.entries[key] = e
+	}
+
+	var limit RateLimit
+	if s.config.Algorithm == AlgoSlidingWindow {
+		limit = s.slidingWindow(e, now)
+	} else {
+		limit = s.tokenBucket(e, now)
+	}
+
+	e.last = now
+	limit.Limit = s.config.Limit
+
+	return limit, nil
+}
+
+// SetLimit changes the limit and window while serving, i.e. when config is
+// reloaded. Clients keep their state, values <= 0 are left as they were.
+// A defaulted ExpiresIn stays 3 Windows.
+func (s *MemoryStore) SetLimit(limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit > 0 {
+		s.config.Limit = limit
+	}
+
+	if window > 0 {
+		s.config.Window = window
+		if s.expiresDefault {
+			s.config.ExpiresIn = 3 * window
+		}
+	}
+}
+
+// Len returns the number of keys currently tracked
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+func (s *MemoryStore) tokenBucket(e *rateEntry, now time.Time) RateLimit {
+	limit := float64(s.config.Limit)
+	perToken := s.config.Window / time.Duration(s.config.Limit)
+
+	e.tokens = math.Min(limit, e.tokens+float64(now.Sub(e.last))/float64(perToken))
+
+	var result RateLimit
+	if e.tokens >= 1 {
+		e.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - e.tokens) * float64(perToken))
+	}
+
+	result.Remaining = int(e.tokens)
+	result.Reset = time.Duration((limit - e.tokens) * float64(perToken))
+
+	return result
+}
+
+func (s *MemoryStore) slidingWindow(e *rateEntry, now time.Time) RateLimit {
+	window := s.config.Window
+
+	// roll the fixed windows forward, the estimate weights the previous one by
+	// how much of it still overlaps the sliding window
+	if elapsed := now.Sub(e.windowStart); elapsed >= window {
+		if elapsed >= 2*window {
+			e.previous = 0
+		} else {
+			e.previous = e.current
+		}
+
+		e.current = 0
+		e.windowStart = e.windowStart.Add(elapsed / window * window)
+	}
+
+	into := now.Sub(e.windowStart)
+	weight := float64(window-into) / float64(window)
+	estimate := float64(e.previous)*weight + float64(e.current)
+
+	var result RateLimit
+	switch {
+	case estimate+1 <= float64(s.config.Limit):
+		e.current++
+		estimate++
+		result.Allowed = true
+	case e.current < s.config.Limit:
+		// wait for enough of the previous window to slide out
+		needed := (estimate + 1 - float64(s.config.Limit)) / float64(e.previous)
+		result.RetryAfter = time.Duration(needed * float64(window))
+	default:
+		result.RetryAfter = window - into
+	}
+
+	result.Remaining = int(math.Max(0, float64(s.config.Limit)-estimate))
+
+	switch {
+	case e.current > 0:
+		result.Reset = 2*window - into
+	case e.previous > 0:
+		result.Reset = window - into
+	}
+
+	return result
+}
+
+// sweep evicts idle keys, at most once per ExpiresIn
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.config.ExpiresIn {
+		return
+	}
+
+	for k, e := range s.entries {
+		if now.Sub(e.last) >= s.config.ExpiresIn {
+			delete(s.entries, k)
+		}
+	}
+
+	s.lastSweep = now
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
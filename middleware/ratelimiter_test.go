@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestStore(config MemoryStoreConfig) (*MemoryStore, *clock) {
+	clk := &clock{now: time.Unix(1500000000, 0)}
+	store := NewMemoryStore(config)
+	store.now = clk.Now
+
+	return store, clk
+}
+
+func TestTokenBucket(t *testing.T) {
+	store, clk := newTestStore(MemoryStoreConfig{Limit: 2, Window: 2 * time.Second})
+
+	first, _ := store.Allow("key")
+	second, _ := store.Allow("key")
+	third, _ := store.Allow("key")
+
+	assert.True(t, first.Allowed)
+	assert.True(t, second.Allowed)
+	assert.Equal(t, 0, second.Remaining)
+	assert.False(t, third.Allowed)
+	assert.Equal(t, time.Second, third.RetryAfter)
+
+	clk.now = clk.now.Add(time.Second)
+
+	fourth, _ := store.Allow("key")
+	assert.True(t, fourth.Allowed)
+
+	other, _ := store.Allow("other")
+	assert.True(t, other.Allowed)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	store, clk := newTestStore(MemoryStoreConfig{Algorithm: AlgoSlidingWindow, Limit: 2, Window: time.Minute})
+
+	first, _ := store.Allow("key")
+	second, _ := store.Allow("key")
+	third, _ := store.Allow("key")
+
+	assert.True(t, first.Allowed)
+	assert.True(t, second.Allowed)
+	assert.False(t, third.Allowed)
+	assert.Equal(t, time.Minute, third.RetryAfter)
+
+	// halfway into the next window the previous one still counts for half
+	clk.now = clk.now.Add(90 * time.Second)
+
+	fourth, _ := store.Allow("key")
+	fifth, _ := store.Allow("key")
+
+	assert.True(t, fourth.Allowed)
+	assert.False(t, fifth.Allowed)
+}
+
+func TestMemoryStoreSetLimit(t *testing.T) {
+	store := NewMemoryStore(MemoryStoreConfig{Limit: 1, Window: time.Minute})
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	l, _ := store.Allow("a")
+	assert.True(t, l.Allowed)
+	l, _ = store.Allow("a")
+	assert.False(t, l.Allowed)
+
+	// raised while serving, the client keeps its state
+	store.SetLimit(3, 0)
+	now = now.Add(time.Minute / 3)
+
+	l, _ = store.Allow("a")
+	assert.True(t, l.Allowed)
+	assert.Equal(t, 3, l.Limit)
+
+	// a defaulted expiry follows the window, a configured one is kept
+	store.SetLimit(0, time.Hour)
+	assert.Equal(t, 3*time.Hour, store.config.ExpiresIn)
+
+	store = NewMemoryStore(MemoryStoreConfig{Window: time.Minute, ExpiresIn: 10 * time.Minute})
+	store.SetLimit(0, time.Hour)
+	assert.Equal(t, 10*time.Minute, store.config.ExpiresIn)
+}
+
+func TestMemoryStoreEvictsIdleKeys(t *testing.T) {
+	store, clk := newTestStore(MemoryStoreConfig{Limit: 1, Window: time.Second, ExpiresIn: time.Minute})
+
+	store.Allow("a")
+	store.Allow("b")
+	assert.Equal(t, 2, store.Len())
+
+	clk.now = clk.now.Add(2 * time.Minute)
+	store.Allow("c")
+
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestRateLimiter(t *testing.T) {
+	g := grinder.New()
+
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, RateLimiter(NewMemoryStore(MemoryStoreConfig{Limit: 1, Window: time.Minute})))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 429, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	// a different client has its own limit
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+}
+
+func TestRateLimiterKeyFuncs(t *testing.T) {
+	g := grinder.New()
+
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, RateLimiterWithConfig(RateLimiterConfig{KeyFunc: KeyByHeader("X-API-Key")}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 401, rec.Code)
+}
+
+func TestRateLimiterKeyByJWTSubject(t *testing.T) {
+	key := []byte("secret")
+
+	g := grinder.New()
+	g.Before(JWTWithConfig(JWTConfig{SigningKey: key}))
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	}, RateLimiterWithConfig(RateLimiterConfig{
+		Store:   NewMemoryStore(MemoryStoreConfig{Limit: 1, Window: time.Minute}),
+		KeyFunc: KeyByJWTSubject,
+	}))
+
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "user-1"}).SignedString(key)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-2"}`))
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/?token="+token, nil))
+	assert.Equal(t, 200, rec.Code)
+
+	// an unverified bearer token doesn't get a bucket of its own
+	req := httptest.NewRequest("GET", "/?token="+token, nil)
+	req.Header.Set("Authorization", "Bearer header."+payload+".signature")
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Equal(t, 429, rec.Code)
+
+	// without JWT there is no subject to limit by
+	c := g.NewContext(httptest.NewRecorder(), req)
+	_, err := KeyByJWTSubject(c)
+	assert.Equal(t, ErrRateLimitKey, err)
+}
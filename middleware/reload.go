@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/rinkbase/grinder"
+)
+
+// Reloadable is a Middleware which can be replaced while serving, i.e. CORS
+// rebuilt with new origins when config changes. Requests already in the
+// middleware finish with the one they started with.
+type Reloadable struct {
+	m atomic.Pointer[grinder.Middleware]
+}
+
+// NewReloadable creates a Reloadable starting out as m
+func NewReloadable(m grinder.Middleware) *Reloadable {
+	r := &Reloadable{}
+	r.Set(m)
+
+	return r
+}
+
+// Set replaces the middleware for requests from now on
+func (r *Reloadable) Set(m grinder.Middleware) {
+	r.m.Store(&m)
+}
+
+// Middleware runs the current middleware, pass it to Before, a Group or a
+// route
+func (r *Reloadable) Middleware(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+	return (*r.m.Load())(ctx, handler)
+}
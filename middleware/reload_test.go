@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadable(t *testing.T) {
+	cors := NewReloadable(CORSWithConfig(CORSConfig{AllowedOrigins: []string{"https://a.example"}}))
+
+	g := grinder.New()
+	g.Before(cors.Middleware)
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	origin := func(o string) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Origin", o)
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+
+		return rec.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	assert.Equal(t, "https://a.example", origin("https://a.example"))
+	assert.Empty(t, origin("https://b.example"))
+
+	// swapped while requests are running
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := g.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			cors.Middleware(c, func(c grinder.Context) error {
+				return c.Code(200)
+			})(c)
+		}()
+	}
+	cors.Set(CORSWithConfig(CORSConfig{AllowedOrigins: []string{"https://b.example"}}))
+	wg.Wait()
+
+	assert.Equal(t, "https://b.example", origin("https://b.example"))
+	assert.Empty(t, origin("https://a.example"))
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rinkbase/grinder"
+)
+
+// RequestIDConfig configuration for RequestID middleware
+type RequestIDConfig struct {
+	Header    string        // Defaults to X-Request-ID
+	Generator func() string // Defaults to UUIDv4 (options: UUIDv4, ULID or any func() string)
+}
+
+// DefaultRequestIDConfig handles the default RequestID configuration for grinder
+var DefaultRequestIDConfig = RequestIDConfig{
+	Header:    "X-Request-ID",
+	Generator: UUIDv4,
+}
+
+// maxRequestIDLength bounds incoming ids so clients can not flood the logs
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// requestIDValue is the id of a request with the header it travels in
+type requestIDValue struct {
+	id     string
+	header string
+}
+
+// RequestID middleware reuses the incoming X-Request-ID or generates a UUIDv4
+func RequestID(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+	return defaultRequestID(ctx, handler)
+}
+
+var defaultRequestID = RequestIDWithConfig(DefaultRequestIDConfig)
+
+// RequestIDWithConfig returns a configured RequestID middleware. The id is set
+// on the response header and stored in the context for the rest of the chain,
+// see RequestIDFromContext.
+func RequestIDWithConfig(config RequestIDConfig) grinder.Middleware {
+	if config.Header == "" {
+		config.Header = DefaultRequestIDConfig.Header
+	}
+
+	if config.Generator == nil {
+		config.Generator = DefaultRequestIDConfig.Generator
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			id := c.GetHeader(config.Header)
+			if !validRequestID(id) {
+				id = config.Generator()
+			}
+
+			c.SetHeader(config.Header, id)
+
+			return handler(c.WithContext(context.WithValue(c.Request().Context(), requestIDKey{}, requestIDValue{id, config.Header})))
+		}
+	}
+}
+
+// RequestIDFromContext returns the request id stored by the RequestID
+// middleware, ctx may be a grinder.Context or any context derived from it
+func RequestIDFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(requestIDKey{}).(requestIDValue)
+	return v.id
+}
+
+// RequestIDTransport returns a http.RoundTripper which forwards the request id
+// found in the outbound request's context, in the header RequestID was
+// configured with. next defaults to http.DefaultTransport
+func RequestIDTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		v, _ := r.Context().Value(requestIDKey{}).(requestIDValue)
+		if v.id == "" || r.Header.Get(v.header) != "" {
+			return next.RoundTrip(r)
+		}
+
+		r = r.Clone(r.Context())
+		r.Header.Set(v.header, v.id)
+
+		return next.RoundTrip(r)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// UUIDv4 generates a random RFC 4122 version 4 UUID
+func UUIDv4() string {
+	var b [16]byte
+	rand.Read(b[:])
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// crockford is the base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates a lexically sortable identifier from the current time and
+// 80 bits of randomness
+func ULID() string {
+	var b [16]byte
+
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	rand.Read(b[6:])
+
+	// 128 bits as 26 characters of 5 bits, the first holding only 3
+	hi := binary.BigEndian.Uint64(b[0:8])
+	lo := binary.BigEndian.Uint64(b[8:16])
+
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDGenerated(t *testing.T) {
+	g := grinder.New()
+
+	var id string
+	g.GET("/", func(c grinder.Context) error {
+		id = RequestIDFromContext(c)
+		return c.Code(200)
+	}, RequestID)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+	assert.Equal(t, id, rec.Header().Get("X-Request-ID"))
+}
+
+func TestRequestIDReused(t *testing.T) {
+	g := grinder.New()
+
+	var id string
+	g.GET("/", func(c grinder.Context) error {
+		id = RequestIDFromContext(c)
+		return c.Code(200)
+	}, RequestIDWithConfig(RequestIDConfig{Header: "X-Correlation-ID", Generator: ULID}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Correlation-ID", "abc-123")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, "abc-123", id)
+	assert.Equal(t, "abc-123", rec.Header().Get("X-Correlation-ID"))
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Correlation-ID", "not valid\n")
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Regexp(t, regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`), id)
+}
+
+func TestULIDSortable(t *testing.T) {
+	a := ULID()
+	b := ULID()
+
+	assert.Len(t, a, 26)
+	assert.True(t, a[:10] <= b[:10])
+}
+
+func TestRequestIDLogged(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	g := grinder.New()
+	g.Before(RequestID, LoggerWithConfig(LoggerConfig{Output: buf}))
+
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	record := make(map[string]interface{})
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &record)) {
+		assert.Equal(t, rec.Header().Get("X-Request-ID"), record["request_id"])
+	}
+}
+
+func TestRequestIDCustomHeader(t *testing.T) {
+	var forwarded string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("X-Correlation-ID")
+	}))
+	defer upstream.Close()
+
+	buf := new(bytes.Buffer)
+
+	g := grinder.New()
+	g.Around(
+		RequestIDWithConfig(RequestIDConfig{Header: "X-Correlation-ID"}),
+		LoggerWithConfig(LoggerConfig{Output: buf, RequestIDHeader: "X-Correlation-ID"}),
+	)
+
+	g.GET("/", func(c grinder.Context) error {
+		client := &http.Client{Transport: RequestIDTransport(nil)}
+
+		req, _ := http.NewRequestWithContext(c, "GET", upstream.URL, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		return c.Code(200)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Correlation-ID", "abc-123")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, "abc-123", forwarded)
+
+	// the logger runs outside RequestID and reads the response header
+	record := make(map[string]interface{})
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &record)) {
+		assert.Equal(t, "abc-123", record["request_id"])
+	}
+}
+
+func TestRequestIDTransport(t *testing.T) {
+	var forwarded string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("X-Request-ID")
+	}))
+	defer upstream.Close()
+
+	g := grinder.New()
+	g.GET("/", func(c grinder.Context) error {
+		client := &http.Client{Transport: RequestIDTransport(nil)}
+
+		req, _ := http.NewRequestWithContext(c, "GET", upstream.URL, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		return c.Code(200)
+	}, RequestID)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "abc-123", forwarded)
+}
@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rinkbase/grinder"
+)
+
+// SecureConfig configuration for Secure middleware, empty values leave the
+// header out
+type SecureConfig struct {
+	HSTSMaxAge            int // Seconds, only sent when Scheme is https
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ContentTypeNosniff    string
+	FrameOptions          string // i.e. DENY, SAMEORIGIN
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+	ContentSecurityPolicy *CSP
+	CSPReportOnly         bool
+}
+
+// DefaultSecureConfig handles the default Secure configuration for grinder
+var DefaultSecureConfig = SecureConfig{
+	HSTSMaxAge:            31536000,
+	HSTSIncludeSubdomains: true,
+	ContentTypeNosniff:    "nosniff",
+	FrameOptions:          "DENY",
+	ReferrerPolicy:        "strict-origin-when-cross-origin",
+	PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+	ContentSecurityPolicy: NewCSP().
+		Directive("default-src", "'self'").
+		Directive("object-src", "'none'").
+		Directive("base-uri", "'self'").
+		Directive("frame-ancestors", "'none'"),
+}
+
+// CSPNonce is the placeholder replaced by the request's nonce in CSP
+// directive sources, i.e. Directive("script-src", "'self'", CSPNonce)
+const CSPNonce = "'nonce'"
+
+// CSP builds a Content-Security-Policy
+type CSP struct {
+	directives map[string][]string
+}
+
+// NewCSP creates an empty Content-Security-Policy
+func NewCSP() *CSP {
+	return &CSP{directives: make(map[string][]string)}
+}
+
+// Directive sets the sources of a directive, replacing any set before
+func (p *CSP) Directive(name string, sources ...string) *CSP {
+	p.directives[name] = sources
+	return p
+}
+
+// Clone returns a copy which can be changed without affecting p, i.e. to
+// override a default for one route
+func (p *CSP) Clone() *CSP {
+	c := NewCSP()
+	for k, v := range p.directives {
+		c.directives[k] = append([]string{}, v...)
+	}
+
+	return c
+}
+
+// usesNonce reports whether any directive wants a per request nonce
+func (p *CSP) usesNonce() bool {
+	for _, sources := range p.directives {
+		for _, s := range sources {
+			if s == CSPNonce {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// String renders the policy, with nonce substituted for CSPNonce
+func (p *CSP) String(nonce string) string {
+	names := make([]string, 0, len(p.directives))
+	for name := range p.directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		sources := make([]string, 0, len(p.directives[name]))
+		for _, s := range p.directives[name] {
+			if s == CSPNonce {
+				s = "'nonce-" + nonce + "'"
+			}
+			sources = append(sources, s)
+		}
+
+		parts = append(parts, strings.TrimSpace(name+" "+strings.Join(sources, " ")))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+type cspNonceKey struct{}
+
+// Secure middleware sets security headers with sensible defaults
+func Secure(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+	return defaultSecure(ctx, handler)
+}
+
+var defaultSecure = SecureWithConfig(DefaultSecureConfig)
+
+// SecureWithConfig returns a configured Secure middleware. When the policy
+// uses CSPNonce a fresh nonce is generated per request and available to
+// handlers and templates through GetCSPNonce. Attached to a route it
+// replaces the headers set by one in Before.
+func SecureWithConfig(config SecureConfig) grinder.Middleware {
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(config.HSTSMaxAge)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	cspHeader := "Content-Security-Policy"
+	if config.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	var csp *CSP
+	nonces := false
+	static := ""
+	if config.ContentSecurityPolicy != nil {
+		csp = config.ContentSecurityPolicy.Clone()
+		nonces = csp.usesNonce()
+		static = csp.String("")
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			h := c.Response().Header()
+
+			setHeader(h, "X-Content-Type-Options", config.ContentTypeNosniff)
+			setHeader(h, "X-Frame-Options", config.FrameOptions)
+			setHeader(h, "Referrer-Policy", config.ReferrerPolicy)
+			setHeader(h, "Permissions-Policy", config.PermissionsPolicy)
+
+			if hsts != "" && c.Scheme() == "https" {
+				h.Set("Strict-Transport-Security", hsts)
+			} else {
+				h.Del("Strict-Transport-Security")
+			}
+
+			h.Del("Content-Security-Policy")
+			h.Del("Content-Security-Policy-Report-Only")
+
+			if csp == nil {
+				return handler(c)
+			}
+
+			if !nonces {
+				h.Set(cspHeader, static)
+				return handler(c)
+			}
+
+			nonce := newNonce()
+			h.Set(cspHeader, csp.String(nonce))
+
+			return handler(c.WithContext(context.WithValue(c.Request().Context(), cspNonceKey{}, nonce)))
+		}
+	}
+}
+
+// GetCSPNonce returns the nonce of the request's Content-Security-Policy, for
+// use in script and style tags
+func GetCSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// setHeader sets key, or removes it when value is empty so route level
+// config can drop a header set by Before
+func setHeader(h http.Header, key string, value string) {
+	if value == "" {
+		h.Del(key)
+		return
+	}
+
+	h.Set(key, value)
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return base64.StdEncoding.EncodeToString(b)
+}
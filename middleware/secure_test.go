@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecureDefaults(t *testing.T) {
+	g := grinder.New()
+	g.Before(Secure)
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "strict-origin-when-cross-origin", rec.Header().Get("Referrer-Policy"))
+	assert.NotEmpty(t, rec.Header().Get("Permissions-Policy"))
+	assert.Equal(t, "base-uri 'self'; default-src 'self'; frame-ancestors 'none'; object-src 'none'", rec.Header().Get("Content-Security-Policy"))
+
+	// HSTS is only meaningful over https
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, "max-age=31536000; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+
+	// X-Forwarded-Proto only counts from a trusted proxy
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+
+	g.TrustProxies("192.0.2.0/24")
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+	assert.NotEmpty(t, rec.Header().Get("Strict-Transport-Security"))
+}
+
+func TestSecureNonce(t *testing.T) {
+	g := grinder.New()
+
+	var nonce string
+	g.GET("/", func(c grinder.Context) error {
+		nonce = GetCSPNonce(c)
+		return c.String(200, `<script nonce="`+nonce+`"></script>`)
+	}, SecureWithConfig(SecureConfig{
+		ContentSecurityPolicy: NewCSP().Directive("script-src", "'self'", CSPNonce),
+	}))
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	first := nonce
+
+	assert.NotEmpty(t, first)
+	assert.Equal(t, "script-src 'self' 'nonce-"+first+"'", rec.Header().Get("Content-Security-Policy"))
+
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.NotEqual(t, first, nonce)
+}
+
+func TestSecureRouteOverride(t *testing.T) {
+	g := grinder.New()
+	g.Before(Secure)
+
+	config := DefaultSecureConfig
+	config.FrameOptions = ""
+	config.ContentSecurityPolicy = DefaultSecureConfig.ContentSecurityPolicy.Clone().Directive("frame-ancestors", "https://partner.com")
+	config.CSPReportOnly = true
+
+	g.GET("/embed", func(c grinder.Context) error {
+		return c.Code(200)
+	}, SecureWithConfig(config))
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/embed", nil))
+
+	assert.Empty(t, rec.Header().Get("X-Frame-Options"))
+	assert.Empty(t, rec.Header().Get("Content-Security-Policy"))
+	assert.True(t, strings.Contains(rec.Header().Get("Content-Security-Policy-Report-Only"), "frame-ancestors https://partner.com"))
+
+	// the default is untouched
+	assert.True(t, strings.Contains(DefaultSecureConfig.ContentSecurityPolicy.String(""), "frame-ancestors 'none'"))
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rinkbase/grinder"
+)
+
+// TimeoutConfig configuration for Timeout middleware
+type TimeoutConfig struct {
+	Timeout time.Duration
+	Code    int         // Defaults to 503 (options: 503, 504)
+	Message interface{} // Defaults to the status text of Code
+}
+
+// DefaultTimeoutConfig handles the default Timeout configuration for grinder
+var DefaultTimeoutConfig = TimeoutConfig{
+	Timeout: 30 * time.Second,
+	Code:    http.StatusServiceUnavailable,
+}
+
+type timeoutKey struct{}
+
+// timeoutScope lets a Timeout nested further down the chain (i.e. on a route)
+// take over from one higher up (i.e. in Before)
+type timeoutScope struct {
+	parent     context.Context
+	overridden int32
+}
+
+// Timeout bounds handler execution to d
+func Timeout(d time.Duration) grinder.Middleware {
+	config := DefaultTimeoutConfig
+	config.Timeout = d
+
+	return TimeoutWithConfig(config)
+}
+
+// TimeoutWithConfig returns a configured Timeout middleware. The handler runs
+// with a context cancelled after config.Timeout, if it has not returned by
+// then an *HTTPError with config.Code is returned and anything the handler
+// writes afterwards is discarded.
+func TimeoutWithConfig(config TimeoutConfig) grinder.Middleware {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeoutConfig.Timeout
+	}
+
+	if config.Code == 0 {
+		config.Code = DefaultTimeoutConfig.Code
+	}
+
+	if config.Message == nil {
+		config.Message = http.StatusText(config.Code)
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			parent := c.Request().Context()
+
+			// an enclosing Timeout steps aside so route level timeouts can
+			// extend as well as shorten the deadline
+			if outer, ok := c.Value(timeoutKey{}).(*timeoutScope); ok {
+				atomic.StoreInt32(&outer.overridden, 1)
+				parent = outer.parent
+			}
+
+			scope := &timeoutScope{parent: parent}
+			tctx, cancel := context.WithTimeout(context.WithValue(parent, timeoutKey{}, scope), config.Timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{w: c.Response(), h: c.Response().Header().Clone(), ctx: tctx, scope: scope}
+			tc := c.WithContext(tctx)
+			tc.SetResponse(grinder.NewResponse(tw))
+
+			done := make(chan error, 1)
+			panicked := make(chan interface{}, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+
+				done <- handler(tc)
+			}()
+
+			select {
+			case err := <-done:
+				// finishing after the deadline only counts if something was written in time
+				if tctx.Err() == nil || scope.isOverridden() || !tw.timeout() {
+					return err
+				}
+			case p := <-panicked:
+				panic(p)
+			case <-tctx.Done():
+				if scope.isOverridden() {
+					select {
+					case err := <-done:
+						return err
+					case p := <-panicked:
+						panic(p)
+					}
+				}
+
+				tw.timeout()
+			}
+
+			// the request itself went away, this is not a timeout
+			if err := c.Err(); err != nil {
+				return err
+			}
+
+			return &grinder.HTTPError{
+				Code:    config.Code,
+				Message: config.Message,
+				Inner:   fmt.Errorf("handler exceeded timeout of %s", config.Timeout),
+			}
+		}
+	}
+}
+
+func (s *timeoutScope) isOverridden() bool {
+	return atomic.LoadInt32(&s.overridden) == 1
+}
+
+// timeoutWriter guards the response from a handler that may outlive its
+// deadline, headers are buffered until the handler writes the status
+type timeoutWriter struct {
+	mu          sync.Mutex
+	w           http.ResponseWriter
+	h           http.Header
+	ctx         context.Context
+	scope       *timeoutScope
+	timedOut    bool
+	wroteHeader bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.writeHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.expired() || tw.wroteHeader {
+		return
+	}
+
+	tw.wroteHeader = true
+
+	for k, v := range tw.h {
+		tw.w.Header()[k] = v
+	}
+
+	tw.w.WriteHeader(code)
+}
+
+// expired reports whether the deadline has passed, writes racing the
+// middleware noticing it are refused as well
+func (tw *timeoutWriter) expired() bool {
+	return tw.timedOut || (tw.ctx.Err() != nil && !tw.scope.isOverridden())
+}
+
+// timeout refuses any further writes, reporting whether the handler had left
+// the response untouched
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.timedOut = true
+	return !tw.wroteHeader
+}
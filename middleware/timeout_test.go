@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutHandlerFinishes(t *testing.T) {
+	g := grinder.New()
+
+	g.GET("/", func(c grinder.Context) error {
+		c.SetHeader("X-Test", "1")
+		return c.JSON(200, "This is a test")
+	}, Timeout(time.Second))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-Test"))
+	assert.Equal(t, "\"This is a test\"", rec.Body.String())
+}
+
+func TestTimeoutHandlerOverruns(t *testing.T) {
+	g := grinder.New()
+
+	written := make(chan error, 1)
+
+	g.GET("/", func(c grinder.Context) error {
+		<-c.Done()
+		_, err := c.Response().Write([]byte("too late"))
+		written <- err
+		return nil
+	}, TimeoutWithConfig(TimeoutConfig{
+		Timeout: 10 * time.Millisecond,
+		Code:    http.StatusGatewayTimeout,
+		Message: "timed out",
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.Equal(t, "\"timed out\"", rec.Body.String())
+	assert.Equal(t, http.ErrHandlerTimeout, <-written)
+}
+
+func TestTimeoutRouteOverride(t *testing.T) {
+	g := grinder.New()
+	g.Before(Timeout(10 * time.Millisecond))
+
+	g.GET("/slow", func(c grinder.Context) error {
+		time.Sleep(50 * time.Millisecond)
+		return c.JSON(200, "This is a test")
+	}, Timeout(time.Second))
+
+	req := httptest.NewRequest("GET", "/slow", nil)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+}
+
+func TestTimeoutPanicPropagates(t *testing.T) {
+	g := grinder.New()
+
+	g.GET("/", func(c grinder.Context) error {
+		panic("boom")
+	}, Timeout(time.Second))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+
+	assert.Panics(t, func() {
+		g.ServeHTTP(rec, req)
+	})
+}
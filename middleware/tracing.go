@@ -0,0 +1,269 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rinkbase/grinder"
+)
+
+// TracingConfig configuration for Tracing middleware
+type TracingConfig struct {
+	Exporter   SpanExporter // Spans are only propagated when nil
+	SampleRate float64      // Fraction of new traces sampled, defaults to 1. Incoming traces keep their decision
+	Skip       []string     // Request paths which get no span, i.e. health checks
+}
+
+// DefaultTracingConfig handles the default Tracing configuration for grinder
+var DefaultTracingConfig = TracingConfig{
+	SampleRate: 1,
+}
+
+// SpanExporter sends finished spans to a tracing backend
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []*Span) error
+}
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid reports whether t is not all zeros
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether s is not all zeros
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// Span is the server side of one request
+type Span struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID // Zero when the trace started here
+	TraceState string // Vendor state passed through from tracestate
+	Sampled    bool
+
+	Name       string // Method and route pattern, i.e. GET /users/:id
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	Status     int    // HTTP status
+	Error      string // From the error the handler returned
+}
+
+// Traceparent renders the span as a W3C traceparent header value
+func (s *Span) Traceparent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + s.TraceID.String() + "-" + s.SpanID.String() + "-" + flags
+}
+
+type spanKey struct{}
+
+// Tracing middleware joins or starts a W3C Trace Context trace per request,
+// without exporting spans
+func Tracing(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+	return defaultTracing(ctx, handler)
+}
+
+var defaultTracing = TracingWithConfig(DefaultTracingConfig)
+
+// TracingWithConfig returns a configured Tracing middleware. The span of the
+// request is available from SpanFromContext, and TracingTransport carries it
+// on to outbound requests.
+func TracingWithConfig(config TracingConfig) grinder.Middleware {
+	if config.SampleRate == 0 {
+		config.SampleRate = DefaultTracingConfig.SampleRate
+	}
+
+	skip := make(map[string]bool)
+	for _, p := range config.Skip {
+		skip[p] = true
+	}
+
+	return func(ctx grinder.Context, handler grinder.Handler) grinder.Handler {
+		return func(c grinder.Context) error {
+			req := c.Request()
+			if skip[req.URL.Path] {
+				return handler(c)
+			}
+
+			span := &Span{
+				SpanID: newSpanID(),
+				Name:   req.Method,
+				Start:  time.Now(),
+				Attributes: map[string]interface{}{
+					"http.request.method": req.Method,
+					"url.path":            req.URL.Path,
+					"url.scheme":          c.Scheme(),
+					"server.address":      c.Host(),
+					"client.address":      c.RealIP(),
+					"user_agent.original": req.UserAgent(),
+				},
+			}
+
+			if traceID, parentID, sampled, ok := parseTraceparent(c.GetHeader("traceparent")); ok {
+				span.TraceID = traceID
+				span.ParentID = parentID
+				span.Sampled = sampled
+				span.TraceState = c.GetHeader("tracestate")
+			} else {
+				span.TraceID = newTraceID()
+				span.Sampled = config.SampleRate >= 1 || mathrand.Float64() < config.SampleRate
+			}
+
+			if route := c.Path(); route != "" {
+				span.Name = req.Method + " " + route
+				span.Attributes["http.route"] = route
+			}
+
+			err := handler(c.WithContext(context.WithValue(req.Context(), spanKey{}, span)))
+			status := grinder.CommitError(c, err)
+			if err != nil {
+				span.Error = err.Error()
+			}
+
+			span.End = time.Now()
+			span.Status = status
+			span.Attributes["http.response.status_code"] = span.Status
+
+			if config.Exporter != nil && span.Sampled {
+				if xerr := config.Exporter.ExportSpans(context.WithoutCancel(req.Context()), []*Span{span}); xerr != nil {
+					log.Println(xerr)
+				}
+			}
+
+			return err
+		}
+	}
+}
+
+// SpanFromContext returns the span of the request, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// TracingTransport returns a http.RoundTripper which continues the trace found
+// in the outbound request's context, next defaults to http.DefaultTransport
+func TracingTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		span := SpanFromContext(r.Context())
+		if span == nil {
+			return next.RoundTrip(r)
+		}
+
+		r = r.Clone(r.Context())
+		r.Header.Set("traceparent", span.Traceparent())
+		if span.TraceState != "" {
+			r.Header.Set("tracestate", span.TraceState)
+		}
+
+		return next.RoundTrip(r)
+	})
+}
+
+// parseTraceparent parses version-format-trace_id-parent_id-trace_flags,
+// accepting later versions as the spec asks
+func parseTraceparent(h string) (traceID TraceID, parentID SpanID, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 {
+		return
+	}
+
+	var version [1]byte
+	if !decodeHex(parts[0], version[:]) || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return
+	}
+
+	if !decodeHex(parts[1], traceID[:]) || !decodeHex(parts[2], parentID[:]) {
+		return
+	}
+
+	var flags [1]byte
+	if !decodeHex(parts[3], flags[:]) {
+		return
+	}
+
+	if !traceID.IsValid() || !parentID.IsValid() {
+		return
+	}
+
+	return traceID, parentID, flags[0]&1 == 1, true
+}
+
+// decodeHex decodes lowercase hex of exactly len(dst) bytes
+func decodeHex(s string, dst []byte) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func newTraceID() (id TraceID) {
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+
+	return id
+}
+
+// InMemoryExporter keeps exported spans in memory, for tests
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// ExportSpans implements SpanExporter
+func (e *InMemoryExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Spans returns the spans exported so far
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]*Span{}, e.spans...)
+}
+
+// Reset forgets the spans exported so far
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rinkbase/grinder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracingNewTrace(t *testing.T) {
+	exporter := &InMemoryExporter{}
+
+	g := grinder.New()
+	g.Around(TracingWithConfig(TracingConfig{Exporter: exporter}))
+
+	var span *Span
+	g.GET("/users/:id", func(c grinder.Context) error {
+		span = SpanFromContext(c.Request().Context())
+		return c.JSON(200, "ok")
+	})
+
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	spans := exporter.Spans()
+	if assert.Len(t, spans, 1) {
+		s := spans[0]
+		assert.Equal(t, span, s)
+		assert.True(t, s.TraceID.IsValid())
+		assert.True(t, s.SpanID.IsValid())
+		assert.False(t, s.ParentID.IsValid())
+		assert.True(t, s.Sampled)
+		assert.Equal(t, "GET /users/:id", s.Name)
+		assert.Equal(t, "/users/:id", s.Attributes["http.route"])
+		assert.Equal(t, 200, s.Attributes["http.response.status_code"])
+		assert.Equal(t, 200, s.Status)
+		assert.Empty(t, s.Error)
+		assert.False(t, s.End.Before(s.Start))
+	}
+}
+
+func TestTracingPropagation(t *testing.T) {
+	exporter := &InMemoryExporter{}
+
+	g := grinder.New()
+	g.Around(TracingWithConfig(TracingConfig{Exporter: exporter}))
+	g.GET("/", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "congo=t61rcWkgMzE")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.Spans()
+	if assert.Len(t, spans, 1) {
+		s := spans[0]
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.TraceID.String())
+		assert.Equal(t, "00f067aa0ba902b7", s.ParentID.String())
+		assert.NotEqual(t, s.ParentID, s.SpanID)
+		assert.Equal(t, "congo=t61rcWkgMzE", s.TraceState)
+	}
+
+	// an unsampled parent is propagated but not exported
+	exporter.Reset()
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Empty(t, exporter.Spans())
+}
+
+func TestTracingErrors(t *testing.T) {
+	exporter := &InMemoryExporter{}
+
+	g := grinder.New()
+	g.Around(TracingWithConfig(TracingConfig{Exporter: exporter, Skip: []string{"/healthz"}}))
+	g.GET("/fail", func(c grinder.Context) error {
+		return errors.New("boom")
+	})
+	g.GET("/teapot", func(c grinder.Context) error {
+		return grinder.NewHTTPError(http.StatusTeapot)
+	})
+	g.GET("/healthz", func(c grinder.Context) error {
+		return c.Code(200)
+	})
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/fail", nil))
+	assert.Equal(t, 500, rec.Code)
+
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/teapot", nil))
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	spans := exporter.Spans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, 500, spans[0].Status)
+		assert.Equal(t, "boom", spans[0].Error)
+		assert.Equal(t, http.StatusTeapot, spans[1].Status)
+		assert.NotEmpty(t, spans[1].Error)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		header string
+		ok     bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false},
+		{"garbage", false},
+		{"", false},
+	}
+
+	for _, tc := range cases {
+		_, _, _, ok := parseTraceparent(tc.header)
+		assert.Equal(t, tc.ok, ok, tc.header)
+	}
+}
+
+func TestTracingTransport(t *testing.T) {
+	var traceparent, tracestate string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		tracestate = r.Header.Get("tracestate")
+	}))
+	defer downstream.Close()
+
+	client := &http.Client{Transport: TracingTransport(nil)}
+
+	g := grinder.New()
+	g.Around(Tracing)
+
+	var span *Span
+	g.GET("/", func(c grinder.Context) error {
+		span = SpanFromContext(c.Request().Context())
+
+		req, _ := http.NewRequestWithContext(c.Request().Context(), "GET", downstream.URL, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		return c.Code(200)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "congo=t61rcWkgMzE")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+span.SpanID.String()+"-01", traceparent)
+	assert.Equal(t, "congo=t61rcWkgMzE", tracestate)
+}
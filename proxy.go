@@ -0,0 +1,161 @@
+package grinder
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rinkbase/grinder/internal/netutil"
+)
+
+// TrustProxies sets the proxies whose forwarding headers are believed, as
+// CIDRs or single addresses, i.e. "10.0.0.0/8" or "::1". By default none are
+// trusted and RealIP is the address of the immediate peer.
+func (g *Grinder) TrustProxies(proxies ...string) error {
+	nets, err := netutil.ParseCIDRs(proxies)
+	if err != nil {
+		return fmt.Errorf("grinder: invalid trusted proxy: %v", err)
+	}
+
+	g.proxies = nets
+	return nil
+}
+
+func (g *Grinder) trusted(ip net.IP) bool {
+	if g == nil || ip == nil {
+		return false
+	}
+
+	for _, n := range g.proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RealIP returns the client address. Forwarded, X-Forwarded-For and
+// X-Real-IP are only consulted when the peer is a trusted proxy, and then
+// read right to left past any further trusted hops.
+func (c *context) RealIP() string {
+	peer := remoteHost(c.request)
+	if !c.grinder.trusted(net.ParseIP(peer)) {
+		return peer
+	}
+
+	hops := forwardedValues(c.request.Header, "for")
+	if len(hops) == 0 {
+		hops = headerValues(c.request.Header, "X-Forwarded-For")
+	}
+	if len(hops) == 0 {
+		hops = headerValues(c.request.Header, "X-Real-IP")
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == nil {
+			// obfuscated or unknown hop, the last known address is all we have
+			break
+		}
+
+		client = ip.String()
+		if !c.grinder.trusted(ip) {
+			break
+		}
+	}
+
+	return client
+}
+
+// Scheme returns http or https as the client sees it, honouring Forwarded
+// and X-Forwarded-Proto from trusted proxies
+func (c *context) Scheme() string {
+	if c.request.TLS != nil {
+		return "https"
+	}
+
+	if c.grinder.trusted(net.ParseIP(remoteHost(c.request))) {
+		if proto := forwardedValues(c.request.Header, "proto"); len(proto) > 0 {
+			return strings.ToLower(proto[0])
+		}
+
+		if proto := headerValues(c.request.Header, "X-Forwarded-Proto"); len(proto) > 0 {
+			return strings.ToLower(proto[0])
+		}
+	}
+
+	return "http"
+}
+
+// Host returns the host the client requested, honouring Forwarded and
+// X-Forwarded-Host from trusted proxies
+func (c *context) Host() string {
+	if c.grinder.trusted(net.ParseIP(remoteHost(c.request))) {
+		if host := forwardedValues(c.request.Header, "host"); len(host) > 0 {
+			return host[0]
+		}
+
+		if host := headerValues(c.request.Header, "X-Forwarded-Host"); len(host) > 0 {
+			return host[0]
+		}
+	}
+
+	return c.request.Host
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// headerValues splits every line of a comma separated header, in order
+func headerValues(h http.Header, key string) []string {
+	var values []string
+
+	for _, line := range h.Values(key) {
+		for _, v := range strings.Split(line, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+
+	return values
+}
+
+// forwardedValues returns the values of param in each element of the RFC
+// 7239 Forwarded header, i.e. for=192.0.2.60;proto=https, for="[2001:db8::1]"
+func forwardedValues(h http.Header, param string) []string {
+	var values []string
+
+	for _, element := range headerValues(h, "Forwarded") {
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], param) {
+				values = append(values, strings.Trim(kv[1], `"`))
+			}
+		}
+	}
+
+	return values
+}
+
+// parseHop parses a forwarded address which may carry a port or brackets
+func parseHop(hop string) net.IP {
+	if ip := net.ParseIP(hop); ip != nil {
+		return ip
+	}
+
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		return net.ParseIP(host)
+	}
+
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
@@ -0,0 +1,70 @@
+package grinder
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealIPUntrusted(t *testing.T) {
+	g := New()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.7:5555"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Forwarded-Host", "evil.example")
+
+	c := g.NewContext(httptest.NewRecorder(), r)
+
+	// headers from an untrusted peer are ignored
+	assert.Equal(t, "203.0.113.7", c.RealIP())
+	assert.Equal(t, "http", c.Scheme())
+	assert.Equal(t, "example.com", c.Host())
+}
+
+func TestRealIPTrusted(t *testing.T) {
+	g := New()
+	assert.NoError(t, g.TrustProxies("10.0.0.0/8", "::1"))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.2:5555"
+	r.Header.Set("X-Forwarded-For", "6.6.6.6, 198.51.100.9, 10.0.0.5")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Forwarded-Host", "api.example.com")
+
+	c := g.NewContext(httptest.NewRecorder(), r)
+
+	// the first untrusted hop from the right, not the spoofable leftmost
+	assert.Equal(t, "198.51.100.9", c.RealIP())
+	assert.Equal(t, "https", c.Scheme())
+	assert.Equal(t, "api.example.com", c.Host())
+
+	r.Header.Del("X-Forwarded-For")
+	r.Header.Set("X-Real-IP", "198.51.100.10")
+	assert.Equal(t, "198.51.100.10", c.RealIP())
+
+	r.Header.Set("Forwarded", `for=192.0.2.60;proto=http;host=www.example.com, for="[2001:db8::1]:4711"`)
+	assert.Equal(t, "2001:db8::1", c.RealIP())
+	assert.Equal(t, "http", c.Scheme())
+	assert.Equal(t, "www.example.com", c.Host())
+
+	r.Header.Set("Forwarded", "for=unknown")
+	assert.Equal(t, "10.0.0.2", c.RealIP())
+
+	r.RemoteAddr = "[::1]:5555"
+	r.Header.Del("Forwarded")
+	assert.Equal(t, "198.51.100.10", c.RealIP())
+
+	r.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "https", c.Scheme())
+}
+
+func TestTrustProxiesInvalid(t *testing.T) {
+	g := New()
+
+	assert.Error(t, g.TrustProxies("10.0.0.0/33"))
+	assert.Error(t, g.TrustProxies("proxy.local"))
+}
@@ -19,12 +19,24 @@ func NewResponse(w http.ResponseWriter) (r *Response) {
 
 // Write will write the bytes (message) to the client
 func (r *Response) Write(b []byte) (n int, err error) {
+	if !r.Committed {
+		r.WriteHeader(http.StatusOK)
+	}
+
 	n, err = r.writer.Write(b)
+	r.Size += int64(n)
 	return
 }
 
-// WriteHeader writes a header to the response writer
+// WriteHeader writes a header to the response writer, only the first call
+// has any effect
 func (r *Response) WriteHeader(code int) {
+	if r.Committed {
+		return
+	}
+
+	r.Status = code
+	r.Committed = true
 	r.writer.WriteHeader(code)
 }
 
@@ -32,3 +44,19 @@ func (r *Response) WriteHeader(code int) {
 func (r *Response) Header() http.Header {
 	return r.writer.Header()
 }
+
+// Flush sends any buffered data to the client, when the writer supports it
+func (r *Response) Flush() {
+	if !r.Committed {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := r.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for http.ResponseController
+func (r *Response) Unwrap() http.ResponseWriter {
+	return r.writer
+}
@@ -2,6 +2,8 @@ package grinder
 
 import (
 	"bytes"
+	"errors"
+	"net/http"
 	"regexp"
 	"strings"
 )
@@ -17,13 +19,18 @@ type Route struct {
 	path       string
 	handler    Handler
 	middleware []Middleware
+	chain      Handler
 }
 
 const pattern = `([aA-zZ0-9_-]+)`
 const query = `[^&?]*?=[^&?]*`
 
-// Add will add a new route to the Router.routes map
-func (r *Router) Add(m string, p string, h Handler, f []Middleware) {
+// wildcard suffix matches the path and everything beneath it
+const wildcard = `/*`
+
+// Add will add a new route to the Router.routes map, constructor middleware
+// in c are composed around the route middleware once, here
+func (r *Router) Add(m string, p string, h Handler, f []Middleware, c ...MiddlewareFunc) {
 	// create new route
 	route := Route{
 		method:  m,
@@ -36,6 +43,26 @@ func (r *Router) Add(m string, p string, h Handler, f []Middleware) {
 		route.middleware = append(route.middleware, v)
 	}
 
+	// route middleware is built per request as it is given the context
+	middleware := route.middleware
+	route.chain = Chain(func(c Context) error {
+		// the body is read after middleware has had the chance to limit it
+		handler := func(c Context) error {
+			if err := parseForm(c); err != nil {
+				return err
+			}
+
+			return h(c)
+		}
+
+		// execute middleware chain
+		for i := 0; i < len(middleware); i++ {
+			handler = middleware[i](c, handler)
+		}
+
+		return handler(c)
+	}, c...)
+
 	// init routes map if its not been already
 	if r.routes == nil {
 		r.routes = make(map[string]map[string]Route)
@@ -63,27 +90,34 @@ func (r *Router) FindRoute(c Context) (bool, Route) {
 
 	routes := r.getRoutes(method)
 
-	if len(routes) != 0 {
-		for k, v := range routes {
-			formatted := format(k)
+	var key, formatted string
+	for k, v := range routes {
+		f := format(k)
 
-			re := regexp.MustCompile(`^` + formatted + `/?$`)
+		re := regexp.MustCompile(`^` + f + `/?$`)
+		if !re.MatchString(method + path[0]) {
+			continue
+		}
 
-			if re.MatchString(method + path[0]) {
-				found = true
-				route = v
+		// exact routes win over wildcard ones, and the longest wildcard prefix
+		// wins over shorter ones, i.e. a mount at /api/v2 over one at /api
+		if found && strings.HasSuffix(k, wildcard) {
+			if !strings.HasSuffix(key, wildcard) || len(k) <= len(key) {
+				continue
+			}
+		}
 
-				// get URL params
-				c.AddParams(r.parseURLParams(method, path[0], formatted, k))
+		found = true
+		route = v
+		key, formatted = k, f
+	}
 
-				// get form params
-				c.Request().ParseForm()
-				c.AddParams(parseFormParams(c.Request().Form))
+	if found {
+		// get URL params
+		c.AddParams(r.parseURLParams(method, path[0], formatted, key))
 
-				if len(path) > 1 {
-					c.AddParams(parseQueryParams(path[1]))
-				}
-			}
+		if len(path) > 1 {
+			c.AddParams(parseQueryParams(path[1]))
 		}
 	}
 
@@ -129,6 +163,25 @@ func parseQueryParams(url string) map[string]string {
 	return params
 }
 
+// parseForm adds the form params unless a param was read already, only a
+// body over its limit is an error
+func parseForm(c Context) error {
+	var err error
+	if cc, ok := c.(*context); ok {
+		err = cc.parseForm()
+	} else {
+		err = c.Request().ParseForm()
+		c.AddParams(parseFormParams(c.Request().Form))
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge)
+	}
+
+	return nil
+}
+
 func parseFormParams(form map[string][]string) map[string]string {
 	params := make(map[string]string)
 
@@ -154,7 +207,11 @@ func format(route string) string {
 
 	re := regexp.MustCompile(`:` + pattern)
 
-	formatted.WriteString(re.ReplaceAllString(route, pattern))
+	formatted.WriteString(re.ReplaceAllString(strings.TrimSuffix(route, wildcard), pattern))
+
+	if strings.HasSuffix(route, wildcard) {
+		formatted.WriteString(`(/.*)?`)
+	}
 
 	return formatted.String()
 }
@@ -1,7 +1,10 @@
 package grinder
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -87,3 +90,51 @@ func TestParseURLParamsOnRootRoute(t *testing.T) {
 
 	assert.True(t, reflect.TypeOf(result).String() == "map[string]string")
 }
+
+func TestFormatWildcard(t *testing.T) {
+	assert.Equal(t, "GET/static(/.*)?", format("GET/static/*"))
+	assert.Equal(t, "GET/users/"+pattern+"(/.*)?", format("GET/users/:id/*"))
+}
+
+func TestFormParamsParsedBeforeHandler(t *testing.T) {
+	g := New()
+
+	g.POST("/path", func(c Context) error {
+		return c.String(200, c.GetParam("name"))
+	})
+
+	r, _ := http.NewRequest("POST", "/path", strings.NewReader("name=grinder"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, "grinder", w.Body.String())
+}
+
+func TestFormParamsAvailableToMiddleware(t *testing.T) {
+	g := New()
+
+	var before, route string
+	g.Before(func(c Context, handler Handler) Handler {
+		before = c.GetParam("token")
+		return handler
+	})
+
+	g.POST("/path", func(c Context) error {
+		return c.String(200, c.GetParam("name"))
+	}, func(c Context, handler Handler) Handler {
+		return func(c Context) error {
+			route = c.GetParam("name")
+			return handler(c)
+		}
+	})
+
+	r, _ := http.NewRequest("POST", "/path", strings.NewReader("name=grinder&token=abc"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	assert.Equal(t, "abc", before)
+	assert.Equal(t, "grinder", route)
+	assert.Equal(t, "grinder", w.Body.String())
+}
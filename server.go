@@ -0,0 +1,448 @@
+package grinder
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// ServerConfig configuration for StartWithConfig, empty values fall back to
+// NAME, VERSION and PORT from the environment or .env, then to
+// DefaultServerConfig. See the config package to set them from a file.
+type ServerConfig struct {
+	Name              string
+	Version           string
+	Address           string // Interface to bind, defaults to all of them, or unix:///path.sock
+	Port              string // Defaults to 8080
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration // Defaults to 10 seconds
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration // Defaults to 2 minutes
+	MaxHeaderBytes    int           // Defaults to http.DefaultMaxHeaderBytes
+
+	// H2C serves HTTP/2 without TLS to clients with prior knowledge, i.e.
+	// service mesh sidecars, alongside HTTP/1. Upgrade: h2c is not supported,
+	// those requests are answered over HTTP/1.1. Protocols already set on a
+	// custom Server are kept.
+	H2C bool
+
+	// Signals trigger a graceful shutdown, Start uses SIGINT and SIGTERM.
+	// Readiness fails for DrainDelay before the listener closes, so load
+	// balancers stop sending requests, then in-flight requests have
+	// ShutdownTimeout to finish.
+	Signals         []os.Signal
+	DrainDelay      time.Duration
+	ShutdownTimeout time.Duration // Defaults to 30 seconds
+
+	// TLS serves https, replacing the TLSConfig of a custom Server
+	TLS *TLSConfig
+
+	// Server is used instead of building one, only its Handler and an empty
+	// Addr are filled in. It serves https when it has a TLSConfig.
+	Server *http.Server
+}
+
+// DefaultServerConfig handles the default server configuration for grinder
+var DefaultServerConfig = ServerConfig{
+	Name:              "grinder",
+	Port:              "8080",
+	ReadHeaderTimeout: 10 * time.Second,
+	IdleTimeout:       2 * time.Minute,
+	MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+	ShutdownTimeout:   30 * time.Second,
+}
+
+// ServerOption sets a field of ServerConfig
+type ServerOption func(*ServerConfig)
+
+// WithName sets the service name
+func WithName(name string) ServerOption {
+	return func(c *ServerConfig) { c.Name = name }
+}
+
+// WithVersion sets the service version
+func WithVersion(version string) ServerOption {
+	return func(c *ServerConfig) { c.Version = version }
+}
+
+// WithAddress sets the interface to bind, i.e. 127.0.0.1
+func WithAddress(address string) ServerOption {
+	return func(c *ServerConfig) { c.Address = address }
+}
+
+// WithPort sets the port to listen on
+func WithPort(port string) ServerOption {
+	return func(c *ServerConfig) { c.Port = port }
+}
+
+// WithReadTimeout sets the time allowed to read a whole request
+func WithReadTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) { c.ReadTimeout = d }
+}
+
+// WithReadHeaderTimeout sets the time allowed to read request headers
+func WithReadHeaderTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) { c.ReadHeaderTimeout = d }
+}
+
+// WithWriteTimeout sets the time allowed to write a response
+func WithWriteTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) { c.WriteTimeout = d }
+}
+
+// WithIdleTimeout sets how long keep-alive connections wait for a request
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) { c.IdleTimeout = d }
+}
+
+// WithMaxHeaderBytes caps the size of request headers
+func WithMaxHeaderBytes(n int) ServerOption {
+	return func(c *ServerConfig) { c.MaxHeaderBytes = n }
+}
+
+// WithH2C serves HTTP/2 without TLS, see ServerConfig.H2C
+func WithH2C() ServerOption {
+	return func(c *ServerConfig) { c.H2C = true }
+}
+
+// WithSignals shuts down gracefully on the signals, i.e. os.Interrupt
+func WithSignals(signals ...os.Signal) ServerOption {
+	return func(c *ServerConfig) { c.Signals = signals }
+}
+
+// WithDrainDelay sets how long readiness fails before the listener closes
+func WithDrainDelay(d time.Duration) ServerOption {
+	return func(c *ServerConfig) { c.DrainDelay = d }
+}
+
+// WithShutdownTimeout sets how long in-flight requests have to finish
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) { c.ShutdownTimeout = d }
+}
+
+// WithServer serves with a custom *http.Server
+func WithServer(s *http.Server) ServerOption {
+	return func(c *ServerConfig) { c.Server = s }
+}
+
+// StartWith starts listening for requests with options applied to an empty
+// ServerConfig, see StartWithConfig
+func (g *Grinder) StartWith(opts ...ServerOption) error {
+	config := ServerConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return g.StartWithConfig(config)
+}
+
+// StartWithConfig starts listening for requests, it blocks until the server
+// stops and returns why instead of exiting the process. After a graceful
+// shutdown it returns nil, or the error from Shutdown.
+func (g *Grinder) StartWithConfig(config ServerConfig) error {
+	srv, config := g.newServer(config)
+
+	l, err := Listen(srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	return g.serve(srv, config, l)
+}
+
+// Serve accepts requests on l, i.e. one from a supervisor or sidecar, with
+// options as for StartWith. Address and Port are not used.
+func (g *Grinder) Serve(l net.Listener, opts ...ServerOption) error {
+	config := ServerConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	srv, config := g.newServer(config)
+
+	return g.serve(srv, config, l)
+}
+
+// Listen listens on a TCP address, i.e. :8080, or a Unix domain socket as
+// unix:///path.sock. A socket file left behind by a previous run is removed.
+func Listen(address string) (net.Listener, error) {
+	if !strings.HasPrefix(address, unixScheme) {
+		return net.Listen("tcp", address)
+	}
+
+	path := strings.TrimPrefix(address, unixScheme)
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	return net.Listen("unix", path)
+}
+
+const unixScheme = "unix://"
+
+// serve accepts requests on l until shutdown
+func (g *Grinder) serve(srv *http.Server, config ServerConfig, l net.Listener) error {
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.build()
+		if err != nil {
+			l.Close()
+			return err
+		}
+
+		srv.TLSConfig = tlsConfig
+	}
+
+	name := config.Name
+	if config.Version != "" {
+		name += " " + config.Version
+	}
+
+	if srv.TLSConfig != nil {
+		fmt.Println("==> Running " + name + " on " + l.Addr().String() + " (tls)")
+
+		return g.run(config, func() error {
+			return srv.ServeTLS(l, "", "")
+		})
+	}
+
+	fmt.Println("==> Running " + name + " on " + l.Addr().String())
+
+	return g.run(config, func() error {
+		return srv.Serve(l)
+	})
+}
+
+// run serves until serve returns, shutting down when a signal arrives
+func (g *Grinder) run(config ServerConfig, serve func() error) error {
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	if len(config.Signals) > 0 {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, config.Signals...)
+		defer signal.Stop(sig)
+
+		go func() {
+			select {
+			case <-sig:
+			case <-stopped:
+				return
+			}
+
+			g.draining.Store(true)
+			time.Sleep(config.DrainDelay)
+
+			ctx, cancel := gocontext.WithTimeout(gocontext.Background(), config.ShutdownTimeout)
+			defer cancel()
+
+			g.Shutdown(ctx)
+		}()
+	}
+
+	err := serve()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	// the server was closed directly, i.e. one given to WithServer
+	g.mu.Lock()
+	shuttingDown, done := g.shuttingDown, g.done
+	g.mu.Unlock()
+
+	if !shuttingDown {
+		return nil
+	}
+
+	// wait for in-flight requests and hooks, the process may exit once we return
+	<-done
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.shutdownErr
+}
+
+// OnShutdown registers a hook run by Shutdown once in-flight requests have
+// finished, i.e. to close database pools or flush logs. Hooks run in reverse
+// order of registration.
+func (g *Grinder) OnShutdown(hook func(gocontext.Context) error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.hooks = append(g.hooks, hook)
+}
+
+// Shutdown stops accepting requests, waits for in-flight ones until ctx is
+// done, then runs the OnShutdown hooks. Readiness fails from the moment it is
+// called. Further calls wait for the first to finish.
+func (g *Grinder) Shutdown(ctx gocontext.Context) error {
+	g.draining.Store(true)
+
+	g.mu.Lock()
+	if g.shuttingDown {
+		done := g.done
+		g.mu.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		return g.shutdownErr
+	}
+
+	g.shuttingDown = true
+	srv := g.server
+	done := g.done
+	hooks := append([]func(gocontext.Context) error{}, g.hooks...)
+	g.mu.Unlock()
+
+	var errs []error
+	if srv != nil {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err := errors.Join(errs...)
+
+	g.mu.Lock()
+	g.shutdownErr = err
+	g.mu.Unlock()
+
+	close(done)
+
+	return err
+}
+
+// Name returns the service name, once the server has started
+func (g *Grinder) Name() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.name
+}
+
+// Version returns the service version, once the server has started
+func (g *Grinder) Version() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.version
+}
+
+// Ready reports whether the service should receive traffic, it turns false
+// once shutdown starts
+func (g *Grinder) Ready() bool {
+	return !g.draining.Load()
+}
+
+// newServer resolves config and builds the server to run g with
+func (g *Grinder) newServer(config ServerConfig) (*http.Server, ServerConfig) {
+	env, _ := godotenv.Read()
+
+	lookup := func(key string) string {
+		if v, ok := os.LookupEnv(key); ok {
+			return v
+		}
+
+		return env[key]
+	}
+
+	if config.Name == "" {
+		config.Name = lookup("NAME")
+	}
+
+	if config.Name == "" {
+		config.Name = DefaultServerConfig.Name
+	}
+
+	if config.Version == "" {
+		config.Version = lookup("VERSION")
+	}
+
+	if config.Port == "" {
+		config.Port = lookup("PORT")
+	}
+
+	if config.Port == "" {
+		config.Port = DefaultServerConfig.Port
+	}
+
+	if config.ReadHeaderTimeout == 0 {
+		config.ReadHeaderTimeout = DefaultServerConfig.ReadHeaderTimeout
+	}
+
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = DefaultServerConfig.IdleTimeout
+	}
+
+	if config.MaxHeaderBytes == 0 {
+		config.MaxHeaderBytes = DefaultServerConfig.MaxHeaderBytes
+	}
+
+	if config.ShutdownTimeout == 0 {
+		config.ShutdownTimeout = DefaultServerConfig.ShutdownTimeout
+	}
+
+	srv := config.Server
+	if srv == nil {
+		srv = &http.Server{
+			ReadTimeout:       config.ReadTimeout,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       config.IdleTimeout,
+			MaxHeaderBytes:    config.MaxHeaderBytes,
+		}
+	}
+
+	if srv.Handler == nil {
+		srv.Handler = g
+	}
+
+	if srv.Addr == "" {
+		srv.Addr = net.JoinHostPort(config.Address, config.Port)
+		if strings.HasPrefix(config.Address, unixScheme) {
+			srv.Addr = config.Address
+		}
+	}
+
+	if config.H2C {
+		protocols := new(http.Protocols)
+		if srv.Protocols != nil {
+			*protocols = *srv.Protocols
+		} else {
+			protocols.SetHTTP1(true)
+			protocols.SetHTTP2(true)
+		}
+
+		protocols.SetUnencryptedHTTP2(true)
+		srv.Protocols = protocols
+	}
+
+	g.mu.Lock()
+	g.name = config.Name
+	g.version = config.Version
+	g.server = srv
+	g.mu.Unlock()
+
+	return srv, config
+}
@@ -0,0 +1,347 @@
+package grinder
+
+import (
+	gocontext "context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+func TestServerConfigDefaults(t *testing.T) {
+	t.Setenv("NAME", "orders")
+	t.Setenv("PORT", "9000")
+
+	g := New()
+	srv, config := g.newServer(ServerConfig{})
+
+	assert.Equal(t, "orders", config.Name)
+	assert.Equal(t, 30*time.Second, config.ShutdownTimeout)
+	assert.Equal(t, ":9000", srv.Addr)
+	assert.Equal(t, 10*time.Second, srv.ReadHeaderTimeout)
+	assert.Equal(t, 2*time.Minute, srv.IdleTimeout)
+	assert.Equal(t, http.DefaultMaxHeaderBytes, srv.MaxHeaderBytes)
+	assert.Equal(t, g, srv.Handler)
+}
+
+func TestServerOptions(t *testing.T) {
+	config := ServerConfig{}
+	for _, opt := range []ServerOption{
+		WithName("billing"),
+		WithAddress("127.0.0.1"),
+		WithPort("7000"),
+		WithReadTimeout(time.Second),
+		WithWriteTimeout(2 * time.Second),
+		WithIdleTimeout(3 * time.Second),
+		WithMaxHeaderBytes(4096),
+	} {
+		opt(&config)
+	}
+
+	srv, config := New().newServer(config)
+
+	assert.Equal(t, "billing", config.Name)
+	assert.Equal(t, "127.0.0.1:7000", srv.Addr)
+	assert.Equal(t, time.Second, srv.ReadTimeout)
+	assert.Equal(t, 2*time.Second, srv.WriteTimeout)
+	assert.Equal(t, 3*time.Second, srv.IdleTimeout)
+	assert.Equal(t, 4096, srv.MaxHeaderBytes)
+
+	custom := &http.Server{Addr: "127.0.0.1:7001"}
+	srv, _ = New().newServer(ServerConfig{Server: custom})
+
+	assert.Equal(t, custom, srv)
+	assert.Equal(t, "127.0.0.1:7001", srv.Addr)
+	assert.NotNil(t, srv.Handler)
+}
+
+// testClient opens a connection per request, so no spare connection the
+// transport dialled keeps Shutdown waiting
+var testClient = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+// waitFor polls url until the server is up
+func waitFor(t *testing.T, url string) {
+	for i := 0; i < 100; i++ {
+		if res, err := testClient.Get(url); err == nil {
+			res.Body.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("server did not start")
+}
+
+func TestStartWith(t *testing.T) {
+	g := New()
+	g.GET("/", func(c Context) error {
+		return c.String(200, "ok")
+	})
+
+	port := freePort(t)
+	go g.StartWith(WithAddress("127.0.0.1"), WithPort(port))
+	waitFor(t, "http://127.0.0.1:"+port+"/")
+
+	res, err := testClient.Get("http://127.0.0.1:" + port + "/")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	assert.NoError(t, g.Shutdown(gocontext.Background()))
+}
+
+func TestStartWithConfigError(t *testing.T) {
+	// errors are returned rather than exiting the process
+	err := New().StartWithConfig(ServerConfig{Address: "127.0.0.1", Port: "99999"})
+	assert.Error(t, err)
+}
+
+func TestShutdownDrains(t *testing.T) {
+	g := New()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	g.GET("/ping", func(c Context) error {
+		return c.Code(200)
+	})
+	g.GET("/slow", func(c Context) error {
+		close(started)
+		<-release
+		return c.String(200, "done")
+	})
+
+	var order []string
+	g.OnShutdown(func(ctx gocontext.Context) error {
+		order = append(order, "db")
+		return nil
+	})
+	g.OnShutdown(func(ctx gocontext.Context) error {
+		order = append(order, "logs")
+		return errors.New("flush failed")
+	})
+
+	port := freePort(t)
+	base := "http://127.0.0.1:" + port
+	stopped := make(chan error)
+	go func() {
+		stopped <- g.StartWith(WithAddress("127.0.0.1"), WithPort(port))
+	}()
+	waitFor(t, base+"/ping")
+	assert.True(t, g.Ready())
+
+	slow := make(chan string)
+	go func() {
+		res, err := testClient.Get(base + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		slow <- string(body)
+	}()
+	<-started
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- g.Shutdown(gocontext.Background())
+	}()
+
+	// readiness fails while the in-flight request is still running
+	for g.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Empty(t, order)
+
+	close(release)
+	assert.Equal(t, "done", <-slow)
+
+	err := <-shutdown
+	assert.EqualError(t, err, "flush failed")
+	assert.Equal(t, []string{"logs", "db"}, order)
+	assert.EqualError(t, <-stopped, "flush failed")
+
+	// later calls wait for the first
+	assert.EqualError(t, g.Shutdown(gocontext.Background()), "flush failed")
+}
+
+func TestShutdownOnSignal(t *testing.T) {
+	g := New()
+	g.GET("/", func(c Context) error {
+		return c.Code(200)
+	})
+
+	closed := false
+	g.OnShutdown(func(ctx gocontext.Context) error {
+		closed = true
+		return nil
+	})
+
+	port := freePort(t)
+	stopped := make(chan error)
+	go func() {
+		stopped <- g.StartWith(WithAddress("127.0.0.1"), WithPort(port), WithSignals(os.Interrupt))
+	}()
+	waitFor(t, "http://127.0.0.1:"+port+"/")
+
+	p, _ := os.FindProcess(os.Getpid())
+	assert.NoError(t, p.Signal(os.Interrupt))
+
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+		assert.True(t, closed)
+		assert.False(t, g.Ready())
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+}
+
+func TestServe(t *testing.T) {
+	g := New()
+	g.GET("/", func(c Context) error {
+		return c.String(200, "ok")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- g.Serve(l)
+	}()
+
+	res, err := testClient.Get("http://" + l.Addr().String() + "/")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	assert.NoError(t, g.Shutdown(gocontext.Background()))
+	assert.NoError(t, <-stopped)
+}
+
+func TestServeClosedDirectly(t *testing.T) {
+	g := New()
+	srv := &http.Server{}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- g.Serve(l, WithServer(srv))
+	}()
+
+	waitFor(t, "http://"+l.Addr().String()+"/")
+	assert.NoError(t, srv.Shutdown(gocontext.Background()))
+
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after the server was shut down")
+	}
+}
+
+func TestStartUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grinder.sock")
+
+	// a socket left behind by a previous run
+	stale, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	g := New()
+	g.GET("/", func(c Context) error {
+		return c.String(200, "ok")
+	})
+
+	go g.StartWith(WithAddress("unix://" + path))
+
+	client := &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx gocontext.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	var res *http.Response
+	for i := 0; i < 100; i++ {
+		if res, err = client.Get("http://grinder/"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	assert.NoError(t, g.Shutdown(gocontext.Background()))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServeH2C(t *testing.T) {
+	g := New()
+	g.GET("/", func(c Context) error {
+		return c.String(200, c.Request().Proto)
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go g.Serve(l, WithH2C())
+	defer g.Shutdown(gocontext.Background())
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+	res, err := client.Get("http://" + l.Addr().String() + "/")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "HTTP/2.0", string(body))
+
+	// HTTP/1 clients are still served
+	res, err = testClient.Get("http://" + l.Addr().String() + "/")
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "HTTP/1.1", string(body))
+}
+
+func TestH2CKeepsServerProtocols(t *testing.T) {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	custom := &http.Server{Protocols: protocols}
+
+	srv, _ := New().newServer(ServerConfig{Server: custom, H2C: true})
+
+	assert.True(t, srv.Protocols.HTTP1())
+	assert.True(t, srv.Protocols.UnencryptedHTTP2())
+	assert.False(t, srv.Protocols.HTTP2())
+
+	// the caller's value is left alone
+	assert.False(t, protocols.UnencryptedHTTP2())
+}
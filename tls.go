@@ -0,0 +1,134 @@
+package grinder
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/rinkbase/grinder/internal/watch"
+)
+
+// TLSConfig configuration for serving https
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	MinVersion   uint16   // Defaults to tls.VersionTLS12
+	CipherSuites []uint16 // TLS 1.2 suites, defaults to Go's
+
+	// ClientCAFile enables mutual TLS, client certificates must be signed by
+	// one of its CAs. The certificate is available from Context.PeerCertificate.
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType // Defaults to tls.RequireAndVerifyClientCert with ClientCAFile
+
+	// CertFile and KeyFile are checked for changes every ReloadInterval, new
+	// connections get the new certificate without a restart
+	ReloadInterval time.Duration // Defaults to 10 seconds
+}
+
+// DefaultTLSConfig handles the default TLS configuration for grinder
+var DefaultTLSConfig = TLSConfig{
+	MinVersion:     tls.VersionTLS12,
+	ReloadInterval: 10 * time.Second,
+}
+
+// WithTLS serves https with config
+func WithTLS(config TLSConfig) ServerOption {
+	return func(c *ServerConfig) { c.TLS = &config }
+}
+
+// StartTLS initiates the framework to listen for https requests with the
+// certificate and key files, see Start
+func (g *Grinder) StartTLS(certFile string, keyFile string) {
+	err := g.StartWithConfig(ServerConfig{
+		Signals: startSignals,
+		TLS:     &TLSConfig{CertFile: certFile, KeyFile: keyFile},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// build loads the certificates and creates the tls.Config to serve with
+func (config TLSConfig) build() (*tls.Config, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("grinder: tls requires a certificate and key file")
+	}
+
+	if config.MinVersion == 0 {
+		config.MinVersion = DefaultTLSConfig.MinVersion
+	}
+
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = DefaultTLSConfig.ReloadInterval
+	}
+
+	certs := &certReloader{
+		certFile: config.CertFile,
+		keyFile:  config.KeyFile,
+		files:    watch.New(config.ReloadInterval, config.CertFile, config.KeyFile),
+	}
+	if err := certs.load(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     config.MinVersion,
+		CipherSuites:   config.CipherSuites,
+		GetCertificate: certs.GetCertificate,
+		ClientAuth:     config.ClientAuth,
+	}
+
+	if config.ClientCAFile != "" {
+		pem, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("grinder: no certificates in %s", config.ClientCAFile)
+		}
+
+		tlsConfig.ClientCAs = pool
+		if tlsConfig.ClientAuth == tls.NoClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// certReloader serves a certificate pair, reloading it when the files change
+type certReloader struct {
+	certFile string
+	keyFile  string
+	files    *watch.Files
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	// a pair which fails to load keeps the certificate it had
+	r.files.Poll(func() {
+		if err := r.load(); err != nil {
+			log.Println(err)
+		}
+	})
+
+	return r.cert.Load(), nil
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.cert.Store(&cert)
+	return nil
+}
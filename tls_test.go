@@ -0,0 +1,215 @@
+package grinder
+
+import (
+	gocontext "context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate for localhost, signed by parent or self
+// signed when parent is nil
+func newTestCert(t *testing.T, name string, parent *testCert, ca bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return &testCert{cert: cert, key: key}
+}
+
+// write saves the certificate and key as PEM files in dir
+func (c *testCert) write(t *testing.T, dir string, name string) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+
+	der, err := x509.MarshalECPrivateKey(c.key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0644))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// tlsClient trusts ca and presents client when given
+func tlsClient(ca *testCert, client *testCert) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	config := &tls.Config{RootCAs: pool}
+	if client != nil {
+		config.Certificates = []tls.Certificate{client.tlsCertificate()}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config, DisableKeepAlives: true}}
+}
+
+func startTLS(t *testing.T, g *Grinder, config TLSConfig) string {
+	port := freePort(t)
+	go g.StartWith(WithAddress("127.0.0.1"), WithPort(port), WithTLS(config))
+	t.Cleanup(func() {
+		g.Shutdown(gocontext.Background())
+	})
+
+	// wait for the listener, a handshake may need a client certificate
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", "127.0.0.1:"+port); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return "https://127.0.0.1:" + port + "/"
+}
+
+func TestStartTLS(t *testing.T) {
+	dir := t.TempDir()
+	server := newTestCert(t, "server", nil, true)
+	certFile, keyFile := server.write(t, dir, "server")
+
+	g := New()
+	g.GET("/", func(c Context) error {
+		return c.String(200, c.Scheme())
+	})
+
+	url := startTLS(t, g, TLSConfig{CertFile: certFile, KeyFile: keyFile})
+
+	res, err := tlsClient(server, nil).Get(url)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	assert.Equal(t, "https", string(body))
+	assert.Equal(t, uint16(tls.VersionTLS13), res.TLS.Version)
+
+	// below the minimum version
+	old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS11,
+	}}}
+	_, err = old.Get(url)
+	assert.Error(t, err)
+}
+
+func TestStartTLSMutual(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, true)
+	server := newTestCert(t, "server", ca, false)
+	client := newTestCert(t, "billing", ca, false)
+
+	certFile, keyFile := server.write(t, dir, "server")
+	caFile, _ := ca.write(t, dir, "ca")
+
+	g := New()
+	g.GET("/", func(c Context) error {
+		if cert := c.PeerCertificate(); cert != nil {
+			return c.String(200, cert.Subject.CommonName)
+		}
+		return c.String(200, "")
+	})
+
+	url := startTLS(t, g, TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+
+	res, err := tlsClient(ca, client).Get(url)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "billing", string(body))
+
+	// no client certificate
+	_, err = tlsClient(ca, nil).Get(url)
+	assert.Error(t, err)
+
+	// one signed by another CA
+	stranger := newTestCert(t, "stranger", nil, true)
+	_, err = tlsClient(ca, stranger).Get(url)
+	assert.Error(t, err)
+}
+
+func TestTLSCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	first := newTestCert(t, "first", nil, true)
+	certFile, keyFile := first.write(t, dir, "server")
+
+	config, err := TLSConfig{CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Nanosecond}.build()
+	assert.NoError(t, err)
+
+	cert, _ := config.GetCertificate(nil)
+	assert.Equal(t, first.cert.Raw, cert.Certificate[0])
+
+	second := newTestCert(t, "second", nil, true)
+	second.write(t, dir, "server")
+
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+
+	cert, _ = config.GetCertificate(nil)
+	assert.Equal(t, second.cert.Raw, cert.Certificate[0])
+
+	// a half written pair keeps the old certificate
+	os.WriteFile(keyFile, []byte("garbage"), 0600)
+	os.Chtimes(keyFile, later.Add(time.Minute), later.Add(time.Minute))
+
+	cert, _ = config.GetCertificate(nil)
+	assert.Equal(t, second.cert.Raw, cert.Certificate[0])
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	_, err := TLSConfig{}.build()
+	assert.Error(t, err)
+
+	_, err = TLSConfig{CertFile: "missing.crt", KeyFile: "missing.key"}.build()
+	assert.Error(t, err)
+
+	err = New().StartWith(WithTLS(TLSConfig{}))
+	assert.Error(t, err)
+}